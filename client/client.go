@@ -0,0 +1,425 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+)
+
+const version = "2.0"
+
+// ErrClosed returned by calls on closed client.
+var ErrClosed = errors.New("jsonrpc2: client is closed")
+
+// Connector establishes connection to JSON-RPC server.
+type Connector interface {
+	Connect(ctx context.Context) (Conn, error)
+}
+
+// Conn is a connection to JSON-RPC server.
+type Conn interface {
+	// Write sends single encoded message (request or batch) to server.
+	Write(ctx context.Context, msg []byte) error
+	// Read blocks until next message from server received.
+	Read() ([]byte, error)
+	// Close closes connection. Blocked Read must return an error.
+	Close() error
+}
+
+type Client struct {
+	conn     Conn
+	logger   rpc.Logger
+	idGen    func() any
+	handlers map[string]rpc.Handler
+	mu       sync.Mutex
+	pending  map[string]chan *response
+	err      error
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	lastRequest chan struct{} // used only by read loop
+}
+
+// Dial connects to server with given connector and returns new client.
+func Dial(ctx context.Context, connector Connector, opts ...Option) (*Client, error) {
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return New(conn, opts...), nil
+}
+
+// New returns client over already established connection.
+func New(conn Conn, opts ...Option) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		conn:     conn,
+		logger:   nopLogger{},
+		idGen:    sequence(),
+		handlers: map[string]rpc.Handler{},
+		pending:  map[string]chan *response{},
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	go c.readLoop()
+	return c
+}
+
+// Close closes underlying connection. All pending calls return ErrClosed.
+func (c *Client) Close() error {
+	err := c.conn.Close()
+	<-c.done
+	return err
+}
+
+// Call calls remote method and decodes its result to result (if it is not nil).
+func (c *Client) Call(ctx context.Context, method string, params any, result any) error {
+	req, err := newRequest(method, params, c.idGen())
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	ch, err := c.register(req.Id)
+	if err != nil {
+		return err
+	}
+	defer c.unregister(req.Id)
+	if err := c.conn.Write(ctx, msg); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		return resp.decode(result)
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Notify sends notification to server. Server does not respond to notifications.
+func (c *Client) Notify(ctx context.Context, method string, params any) error {
+	req, err := newRequest(method, params, nil)
+	if err != nil {
+		return err
+	}
+	msg, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return c.err
+	default:
+	}
+	return c.conn.Write(ctx, msg)
+}
+
+// BatchCall is a single element of batch request.
+type BatchCall struct {
+	Method string
+	Params any
+	// Result is a pointer to value that receives call result. May be nil.
+	Result any
+	// Notify marks element as notification. Server does not respond to it.
+	Notify bool
+	// Error is set after batch completion if this call has failed.
+	Error error
+}
+
+// Batch sends all calls as single batch request and waits for all responses.
+// Returned error relates to whole batch, errors of separate calls are set to BatchCall.Error.
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	reqs := make([]*request, len(calls))
+	chans := make([]chan *response, len(calls))
+	for i, call := range calls {
+		var id any
+		if !call.Notify {
+			id = c.idGen()
+		}
+		req, err := newRequest(call.Method, call.Params, id)
+		if err != nil {
+			return err
+		}
+		reqs[i] = req
+	}
+	msg, err := json.Marshal(reqs)
+	if err != nil {
+		return err
+	}
+	for i, req := range reqs {
+		if req.Id == nil {
+			continue
+		}
+		ch, err := c.register(req.Id)
+		if err != nil {
+			return err
+		}
+		defer c.unregister(req.Id)
+		chans[i] = ch
+	}
+	if err := c.conn.Write(ctx, msg); err != nil {
+		return err
+	}
+	for i, ch := range chans {
+		if ch == nil {
+			continue
+		}
+		select {
+		case resp := <-ch:
+			calls[i].Error = resp.decode(calls[i].Result)
+		case <-c.done:
+			return c.err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Call is a generic wrapper for Client.Call.
+func Call[RQ any, RS any](ctx context.Context, c *Client, method string, req RQ) (RS, error) {
+	resp := new(RS)
+	err := c.Call(ctx, method, req, resp)
+	return *resp, err
+}
+
+// CallS is a generic wrapper for Client.Call without any request params.
+func CallS[RS any](ctx context.Context, c *Client, method string) (RS, error) {
+	resp := new(RS)
+	err := c.Call(ctx, method, nil, resp)
+	return *resp, err
+}
+
+func (c *Client) register(id any) (chan *response, error) {
+	key, err := idKey(id)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *response, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		return nil, c.err
+	}
+	c.pending[key] = ch
+	return ch, nil
+}
+
+func (c *Client) unregister(id any) {
+	key, _ := idKey(id)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, key)
+}
+
+func (c *Client) readLoop() {
+	var err error
+	for {
+		var msg []byte
+		msg, err = c.conn.Read()
+		if err != nil {
+			break
+		}
+		c.dispatch(msg)
+	}
+	c.mu.Lock()
+	c.pending = nil
+	c.err = ErrClosed
+	c.mu.Unlock()
+	c.logger.Logf("Connection closed: %v", err)
+	c.cancel()
+	close(c.done)
+}
+
+func (c *Client) dispatch(msg []byte) {
+	msg = bytes.TrimSpace(msg)
+	if len(msg) == 0 {
+		return
+	}
+	if msg[0] == '[' {
+		batch := []json.RawMessage{}
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			c.logger.Logf("Invalid batch response: %v", err)
+			return
+		}
+		for _, m := range batch {
+			c.dispatch(m)
+		}
+		return
+	}
+	resp := new(response)
+	if err := json.Unmarshal(msg, resp); err != nil {
+		c.logger.Logf("Invalid response: %v", err)
+		return
+	}
+	if resp.Method != "" {
+		// Requests from server are handled one by one in order of receiving
+		// without blocking reading of responses.
+		prev, done := c.lastRequest, make(chan struct{})
+		c.lastRequest = done
+		go func() {
+			defer close(done)
+			if prev != nil {
+				<-prev
+			}
+			c.handleRequest(msg)
+		}()
+		return
+	}
+	key := string(compact(resp.Id))
+	if (key == "" || key == "null") && resp.Error != nil {
+		// Server can't tell which message failed (e.g. message is too large), and on multiplexed connection
+		// it may answer before responses to earlier messages, so error can't be matched to calls.
+		// HTTP connection returns such errors from Write of failed message.
+		c.logger.Logf("Error response without id: %v", *resp.Error)
+		return
+	}
+	c.mu.Lock()
+	ch, ok := c.pending[key]
+	delete(c.pending, key)
+	c.mu.Unlock()
+	if !ok {
+		c.logger.Logf("Unexpected response with id %s", key)
+		return
+	}
+	ch <- resp
+}
+
+// handleRequest executes notification or call sent by server.
+func (c *Client) handleRequest(msg []byte) {
+	req := new(rpc.RpcRequest)
+	if err := json.Unmarshal(msg, req); err != nil {
+		c.logger.Logf("Invalid request from server: %v", err)
+		return
+	}
+	var resp *rpc.RpcResponse
+	h, ok := c.handlers[req.Method]
+	if !ok {
+		c.logger.Logf("Unknown method %s called by server", req.Method)
+		resp = rpc.ErrorResponse(req.Id, rpc.ErrorFromCode(rpc.ErrCodeMethodNotFound))
+	} else if result, err := h.Handle(c.ctx, req.Params); err != nil {
+		var rpcErr rpc.Error
+		if !errors.As(err, &rpcErr) {
+			c.logger.Logf("Handler %s error: %v", req.Method, err)
+			rpcErr = rpc.WrapError(err, "", rpc.ErrCodeInternalError)
+		}
+		resp = rpc.ErrorResponse(req.Id, rpcErr)
+	} else {
+		resp = rpc.ResultResponse(req.Id, result)
+	}
+	if req.IsNotification() {
+		return
+	}
+	out, err := json.Marshal(resp)
+	if err != nil {
+		c.logger.Logf("Can't encode response: %v", err)
+		return
+	}
+	if err := c.conn.Write(c.ctx, out); err != nil {
+		c.logger.Logf("Can't write response: %v", err)
+	}
+}
+
+type request struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      any             `json:"id,omitempty"`
+}
+
+func newRequest(method string, params any, id any) (*request, error) {
+	req := &request{
+		Jsonrpc: version,
+		Method:  method,
+		Id:      id,
+	}
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = p
+	}
+	return req, nil
+}
+
+type response struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpc.Error      `json:"error"`
+	Id      json.RawMessage `json:"id"`
+}
+
+// responseError returns error of message if it is error response without id, which can't be matched to request.
+func responseError(msg []byte) error {
+	resp := new(response)
+	if err := json.Unmarshal(msg, resp); err != nil || resp.Error == nil {
+		return nil
+	}
+	if id := string(compact(resp.Id)); id != "" && id != "null" {
+		return nil
+	}
+	return *resp.Error
+}
+
+func (r *response) decode(result any) error {
+	if r.Error != nil {
+		return *r.Error
+	}
+	if result == nil || len(r.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(r.Result, result)
+}
+
+func idKey(id any) (string, error) {
+	b, err := json.Marshal(id)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func compact(b []byte) []byte {
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, b); err != nil {
+		return b
+	}
+	return buf.Bytes()
+}
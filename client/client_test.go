@@ -0,0 +1,101 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+type echoParams struct {
+	S string `json:"s"`
+}
+
+func newTestServer() *rpc.RpcServer {
+	s := rpc.New(rpc.WithMaxBatchSize(1))
+	s.Register("echo", rpc.H(func(ctx context.Context, p *echoParams) (string, error) {
+		return p.S, nil
+	}))
+	s.Register("slow", rpc.H(func(ctx context.Context, p *echoParams) (string, error) {
+		time.Sleep(100 * time.Millisecond)
+		return p.S, nil
+	}))
+	return s
+}
+
+// TestErrorWithoutID checks that error response without id fails only request it belongs to.
+// HTTP connection knows that request, stream connection doesn't, so such error is only logged.
+func TestErrorWithoutID(t *testing.T) {
+	srv := httptest.NewServer(newTestServer())
+	defer srv.Close()
+	serverConn, clientConn := net.Pipe()
+	framer := transport.NewlineFramer{}
+	go newTestServer().ResolveFrames(context.Background(), framer.NewReader(serverConn), framer.NewWriter(serverConn), true)
+	defer serverConn.Close()
+
+	tooLarge := rpc.ErrorFromCode(rpc.ErrCodeMessageTooLarge)
+	tests := []struct {
+		name     string
+		connect  func(ctx context.Context) (Conn, error)
+		batchErr error
+	}{
+		{"http", (&HTTP{URL: srv.URL}).Connect, tooLarge},
+		{"stream", func(ctx context.Context) (Conn, error) { return newStreamConn(clientConn, framer), nil }, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			conn, err := tt.connect(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := New(conn)
+			defer c.Close()
+			// call executed while batch is rejected must not be failed by error of batch
+			slow := make(chan error, 1)
+			go func() {
+				var res string
+				err := c.Call(ctx, "slow", []string{"hi"}, &res)
+				if err == nil && res != "hi" {
+					err = errors.New("unexpected result " + res)
+				}
+				slow <- err
+			}()
+			time.Sleep(20 * time.Millisecond)
+			batchCtx, cancelBatch := context.WithTimeout(ctx, 300*time.Millisecond)
+			defer cancelBatch()
+			calls := []*BatchCall{{Method: "echo", Params: []string{"a"}}, {Method: "echo", Params: []string{"b"}}}
+			if err := c.Batch(batchCtx, calls); !errors.Is(err, tt.batchErr) {
+				t.Fatalf("Batch() error = %v, want %v", err, tt.batchErr)
+			}
+			if err := <-slow; err != nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+type HTTP struct {
+	URL    string
+	Client *http.Client // Optional http client (default http.DefaultClient)
+	Header http.Header  // Optional additional request headers
+}
+
+func (h *HTTP) Connect(ctx context.Context) (Conn, error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &httpConn{
+		url:      h.URL,
+		client:   client,
+		header:   h.Header,
+		incoming: make(chan []byte),
+		closed:   make(chan struct{}),
+	}, nil
+}
+
+// httpConn sends each message as separate POST request.
+// Response bodies are passed to reader.
+type httpConn struct {
+	url       string
+	client    *http.Client
+	header    http.Header
+	incoming  chan []byte
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *httpConn) Write(ctx context.Context, msg []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(msg))
+	if err != nil {
+		return err
+	}
+	for k, v := range c.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil && resp.StatusCode == http.StatusOK {
+		return err
+	}
+	// Each request has exactly one response, so error without id (e.g. message is too large)
+	// belongs to calls of this request.
+	if err := responseError(body); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jsonrpc2: unexpected http status: %s", resp.Status)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		// response to notification
+		return nil
+	}
+	select {
+	case c.incoming <- body:
+		return nil
+	case <-c.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *httpConn) Read() ([]byte, error) {
+	select {
+	case msg := <-c.incoming:
+		return msg, nil
+	case <-c.closed:
+		return nil, io.EOF
+	}
+}
+
+func (c *httpConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return nil
+}
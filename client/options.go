@@ -0,0 +1,61 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"sync/atomic"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+)
+
+type Option func(c *Client)
+
+// WithIDGenerator sets request id generator. Generated ids must be unique within connection.
+func WithIDGenerator(gen func() any) Option {
+	return func(c *Client) {
+		c.idGen = gen
+	}
+}
+
+// WithHandler registers handler for notifications and calls sent by server over persistent connection.
+func WithHandler(method string, handler rpc.Handler) Option {
+	return func(c *Client) {
+		c.handlers[method] = handler
+	}
+}
+
+func WithLogger(l rpc.Logger) Option {
+	return func(c *Client) {
+		c.logger = l
+	}
+}
+
+// sequence returns default id generator producing sequential numeric ids.
+func sequence() func() any {
+	var id uint64
+	return func() any {
+		return atomic.AddUint64(&id, 1)
+	}
+}
+
+type nopLogger struct{}
+
+func (n nopLogger) Logf(_ string, _ ...interface{}) {
+}
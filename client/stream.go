@@ -0,0 +1,108 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+func dialStream(ctx context.Context, network, addr string, tlsConfig *tls.Config, framer transport.Framer) (Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		if tlsConfig.ServerName == "" && network == "tcp" {
+			// same as tls.Dial does
+			tlsConfig = tlsConfig.Clone()
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	return newStreamConn(conn, framer), nil
+}
+
+// streamConn is a persistent connection transferring framed messages.
+type streamConn struct {
+	conn net.Conn
+	fr   transport.FrameReader
+	fw   transport.FrameWriter
+	mu   sync.Mutex
+}
+
+// newStreamConn returns connection with given framing. Nil framer means stream of concatenated JSON values.
+func newStreamConn(conn net.Conn, framer transport.Framer) *streamConn {
+	c := &streamConn{conn: conn}
+	if framer == nil {
+		js := &jsonStream{dec: json.NewDecoder(conn), w: conn}
+		c.fr, c.fw = js, js
+	} else {
+		c.fr, c.fw = framer.NewReader(conn), framer.NewWriter(conn)
+	}
+	return c
+}
+
+func (c *streamConn) Write(ctx context.Context, msg []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return c.fw.WriteFrame(msg)
+}
+
+func (c *streamConn) Read() ([]byte, error) {
+	return c.fr.ReadFrame()
+}
+
+func (c *streamConn) Close() error {
+	return c.conn.Close()
+}
+
+type jsonStream struct {
+	dec *json.Decoder
+	w   io.Writer
+}
+
+func (s *jsonStream) ReadFrame() ([]byte, error) {
+	msg := json.RawMessage{}
+	if err := s.dec.Decode(&msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *jsonStream) WriteFrame(p []byte) error {
+	_, err := s.w.Write(append(p, '\n'))
+	return err
+}
@@ -0,0 +1,37 @@
+//Package client provides JSON-RPC 2.0 client
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+type TCP struct {
+	Addr   string
+	TLS    *tls.Config      // Optional TLS config. Set Certificates for mutual TLS.
+	Framer transport.Framer // Optional message framing, must match server (default stream of concatenated JSON values)
+}
+
+func (t *TCP) Connect(ctx context.Context) (Conn, error) {
+	return dialStream(ctx, "tcp", t.Addr, t.TLS, t.Framer)
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"errors"
 	"log"
 	"os"
 	"os/signal"
@@ -47,26 +46,10 @@ func main() {
 				},
 				"required": ["quo", "rem"]
 			}
-		},
-		"multiply": {
-			"request": {
-				"type": "object",
-				"properties": {
-					"a": {
-						"type": "integer"
-					},
-					"b": {
-						"type": "integer"
-					}
-				},
-				"required": ["a", "b"]
-			},
-			"response": {
-				"type": "integer"
-			}
 		}
 	}`
 
+	// Methods missing in schema (multiply, hello) are not validated
 	validation, err := middleware.Validation(middleware.MustSchema(serviceSchema))
 	if err != nil {
 		log.Fatal(err)
@@ -95,7 +78,7 @@ func Multiply(ctx context.Context, args *Args) (int, error) {
 
 func Divide(ctx context.Context, args *Args) (*Quotient, error) {
 	if args.B == 0 {
-		return nil, errors.New("divide by zero")
+		return nil, rpc.NewError("divide by zero", rpc.ErrUser)
 	}
 	quo := new(Quotient)
 	quo.Quo = args.A / args.B
@@ -0,0 +1,185 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// CancelRequestMethod is notification that cancels in-flight request of the same connection:
+//
+//	{"jsonrpc": "2.0", "method": "$/cancelRequest", "params": {"id": 1}}
+//
+// Context of handler is cancelled and, if handler fails because of it,
+// client receives ErrCodeRequestCancelled error. Request waiting in queue is not executed at all.
+const CancelRequestMethod = "$/cancelRequest"
+
+type (
+	inflightRequestsKey struct{}
+	inflightKey         struct{}
+)
+
+// inflightRequests tracks requests of persistent connection to cancel them by id.
+type inflightRequests struct {
+	mu       sync.Mutex
+	requests map[string]*inflightRequest
+}
+
+type inflightRequest struct {
+	cancel    context.CancelFunc // set when request starts, guarded by inflightRequests.mu
+	cancelled int32
+}
+
+func newInflightRequests() *inflightRequests {
+	return &inflightRequests{requests: map[string]*inflightRequest{}}
+}
+
+// accept registers requests of message when reader accepts it, so they can be cancelled while waiting in queue.
+// Returned function unregisters them after message is resolved.
+func (rs *inflightRequests) accept(msg json.RawMessage) func() {
+	keys := requestKeys(msg)
+	if len(keys) == 0 {
+		return func() {}
+	}
+	reqs := make([]*inflightRequest, len(keys))
+	rs.mu.Lock()
+	for i, key := range keys {
+		reqs[i] = &inflightRequest{}
+		rs.requests[key] = reqs[i]
+	}
+	rs.mu.Unlock()
+	return func() {
+		rs.mu.Lock()
+		defer rs.mu.Unlock()
+		for i, key := range keys {
+			if rs.requests[key] == reqs[i] {
+				delete(rs.requests, key)
+			}
+		}
+	}
+}
+
+// start returns cancellable context of accepted request and function releasing it.
+// It returns false if request was cancelled before start, so it must not be executed.
+func (rs *inflightRequests) start(ctx context.Context, id any) (context.Context, func(), bool) {
+	key, err := json.Marshal(id)
+	if err != nil {
+		return ctx, func() {}, true
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	release := cancel
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	req, ok := rs.requests[string(key)]
+	if !ok {
+		// request was not accepted by reader, register it until it is released
+		req = &inflightRequest{}
+		rs.requests[string(key)] = req
+		release = func() {
+			cancel()
+			rs.mu.Lock()
+			defer rs.mu.Unlock()
+			if rs.requests[string(key)] == req {
+				delete(rs.requests, string(key))
+			}
+		}
+	}
+	if atomic.LoadInt32(&req.cancelled) == 1 {
+		cancel()
+		return ctx, release, false
+	}
+	req.cancel = cancel
+	return context.WithValue(ctx, inflightKey{}, req), release, true
+}
+
+// handle cancels request if msg is $/cancelRequest notification. Unknown ids are ignored.
+func (rs *inflightRequests) handle(msg json.RawMessage) bool {
+	if isBatch(msg) || !bytes.Contains(msg, []byte(CancelRequestMethod)) {
+		return false
+	}
+	probe := struct {
+		Method string `json:"method"`
+		Params struct {
+			Id json.RawMessage `json:"id"`
+		} `json:"params"`
+	}{}
+	if err := json.Unmarshal(msg, &probe); err != nil || probe.Method != CancelRequestMethod {
+		return false
+	}
+	key, ok := idKey(probe.Params.Id)
+	if !ok {
+		return true
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if req, ok := rs.requests[key]; ok {
+		atomic.StoreInt32(&req.cancelled, 1)
+		if req.cancel != nil {
+			req.cancel()
+		}
+	}
+	return true
+}
+
+// requestKeys returns keys of ids of requests (not notifications) in message.
+func requestKeys(msg json.RawMessage) []string {
+	msgs := []json.RawMessage{}
+	if !isBatch(msg) {
+		msgs = append(msgs, msg)
+	} else if json.Unmarshal(msg, &msgs) != nil {
+		return nil
+	}
+	keys := []string{}
+	for _, m := range msgs {
+		probe := struct {
+			Id json.RawMessage `json:"id"`
+		}{}
+		if json.Unmarshal(m, &probe) != nil || len(probe.Id) == 0 {
+			continue
+		}
+		if key, ok := idKey(probe.Id); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// idKey compacts raw id, so it matches key of id decoded to RpcRequest.
+func idKey(raw json.RawMessage) (string, bool) {
+	var id any
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return "", false
+	}
+	key, err := json.Marshal(id)
+	if err != nil {
+		return "", false
+	}
+	return string(key), true
+}
+
+// cancelledByPeer reports whether request of ctx was cancelled by $/cancelRequest.
+func cancelledByPeer(ctx context.Context) bool {
+	req, ok := ctx.Value(inflightKey{}).(*inflightRequest)
+	return ok && atomic.LoadInt32(&req.cancelled) == 1
+}
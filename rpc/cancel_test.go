@@ -0,0 +1,144 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// wait waits for ctx or d, so handler fails only if its ctx is done before.
+func wait(ctx context.Context, d time.Duration) (int, error) {
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(d):
+		return 1, nil
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	s := New(WithTimeout(20*time.Millisecond), WithMethodTimeout("server", time.Second))
+	s.Register("default", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, time.Second)
+	}))
+	s.Register("method", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, 100*time.Millisecond)
+	}), MethodTimeout(time.Second))
+	s.Register("server", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, 100*time.Millisecond)
+	}))
+	s.Register("short", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, time.Second)
+	}), MethodTimeout(10*time.Millisecond))
+
+	tests := []struct {
+		name   string
+		method string
+		want   []string
+	}{
+		{"server timeout", "default", []string{"1:-32001"}},
+		{"method option overrides server timeout", "method", []string{"1:0"}},
+		{"server option overrides server timeout", "server", []string{"1:0"}},
+		{"method option shortens server timeout", "short", []string{"1:-32001"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resolve(t, s, `{"jsonrpc":"2.0","method":"`+tt.method+`","id":1}`, false)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	s := New()
+	started := make(chan struct{}, 2)
+	executed := int32(0)
+	s.Register("wait", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		atomic.AddInt32(&executed, 1)
+		started <- struct{}{}
+		return wait(ctx, 5*time.Second)
+	}))
+	serverConn, clientConn := net.Pipe()
+	go func() {
+		s.Resolve(context.Background(), serverConn, serverConn, false)
+		serverConn.Close()
+	}()
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	defer clientConn.Close()
+	responses := make(chan testResponse)
+	go func() {
+		dec := json.NewDecoder(clientConn)
+		for {
+			resp := testResponse{}
+			if dec.Decode(&resp) != nil {
+				close(responses)
+				return
+			}
+			responses <- resp
+		}
+	}()
+	write := func(msg string) {
+		t.Helper()
+		if _, err := clientConn.Write([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expect := func(id string, code int) {
+		t.Helper()
+		resp, ok := <-responses
+		if !ok {
+			t.Fatalf("no response to %s", id)
+		}
+		gotCode := 0
+		if resp.Error != nil {
+			gotCode = resp.Error.Code
+		}
+		if string(resp.Id) != id || gotCode != code {
+			t.Fatalf("got response %s:%d, want %s:%d", resp.Id, gotCode, id, code)
+		}
+	}
+
+	// sequential mode: second request waits in queue until first one is resolved
+	write(`{"jsonrpc":"2.0","method":"wait","id":1}`)
+	<-started
+	write(`{"jsonrpc":"2.0","method":"wait","id":"queued"}`)
+	write(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"unknown"}}`)
+	write(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":"queued"}}`)
+	write(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id": 1}}`)
+	expect("1", ErrCodeRequestCancelled)
+	expect(`"queued"`, ErrCodeRequestCancelled)
+	if n := atomic.LoadInt32(&executed); n != 1 {
+		t.Fatalf("handler executed %d times, want 1", n)
+	}
+
+	// id of resolved request may be reused
+	write(`{"jsonrpc":"2.0","method":"wait","id":1}`)
+	<-started
+	write(`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":1}}`)
+	expect("1", ErrCodeRequestCancelled)
+}
@@ -31,13 +31,37 @@ type RpcRequest struct {
 	Method  string          `json:"method"`
 	Params  json.RawMessage `json:"params"`
 	Id      any             `json:"id"`
+
+	hasId bool
+}
+
+// IsNotification reports whether request has no id member, so server must not reply to it.
+func (r *RpcRequest) IsNotification() bool {
+	return r.Id == nil && !r.hasId
+}
+
+func (r *RpcRequest) UnmarshalJSON(b []byte) error {
+	type plain RpcRequest
+	aux := struct {
+		*plain
+		Id json.RawMessage `json:"id"`
+	}{plain: (*plain)(r)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	r.hasId = len(aux.Id) > 0
+	r.Id = nil
+	if r.hasId {
+		return json.Unmarshal(aux.Id, &r.Id)
+	}
+	return nil
 }
 
 type RpcResponse struct {
 	Jsonrpc string          `json:"jsonrpc"`
 	Result  json.RawMessage `json:"result,omitempty"`
 	Error   error           `json:"error,omitempty"`
-	Id      any             `json:"id,omitempty"`
+	Id      any             `json:"id"`
 }
 
 type Flusher interface {
@@ -19,37 +19,100 @@
 
 package rpc
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 const (
-	ErrCodeParseError     = -32700
-	ErrCodeInvalidRequest = -32600
-	ErrCodeMethodNotFound = -32601
-	ErrCodeInvalidParams  = -32602
-	ErrCodeInternalError  = -32603
-	ErrUser               = -32000
+	ErrCodeParseError      = -32700
+	ErrCodeInvalidRequest  = -32600
+	ErrCodeMethodNotFound  = -32601
+	ErrCodeInvalidParams   = -32602
+	ErrCodeInternalError   = -32603
+	ErrUser                = -32000
+	ErrCodeTimeout         = -32001 // Handler did not finish until request timeout.
+	ErrCodeUnauthorized    = -32002 // Caller is not authenticated.
+	ErrCodeForbidden       = -32003 // Caller is not allowed to call method.
+	ErrCodeTooManyRequests = -32004 // Rate or concurrency limit is exceeded, data is RetryAfterData.
+	ErrCodeMessageTooLarge = -32005 // Message exceeds size, batch length or nesting depth limit.
+	// ErrCodeRequestCancelled is returned when request was cancelled by $/cancelRequest notification (same code as in LSP).
+	ErrCodeRequestCancelled = -32800
 )
 
 var errorMap = map[int]string{
-	ErrCodeParseError:     "Parse error",      // Invalid JSON was received by the server. An error occurred on the server while parsing the JSON text.
-	ErrCodeInvalidRequest: "Invalid Request",  // The JSON sent is not a valid Request object.
-	ErrCodeMethodNotFound: "Method not found", // The method does not exist / is not available.
-	ErrCodeInvalidParams:  "Invalid params",   // Invalid method parameter(s).
-	ErrCodeInternalError:  "Internal error",   // Internal JSON-RPC error.
-	ErrUser:               "Other error",
+	ErrCodeParseError:       "Parse error",      // Invalid JSON was received by the server. An error occurred on the server while parsing the JSON text.
+	ErrCodeInvalidRequest:   "Invalid Request",  // The JSON sent is not a valid Request object.
+	ErrCodeMethodNotFound:   "Method not found", // The method does not exist / is not available.
+	ErrCodeInvalidParams:    "Invalid params",   // Invalid method parameter(s).
+	ErrCodeInternalError:    "Internal error",   // Internal JSON-RPC error.
+	ErrUser:                 "Other error",
+	ErrCodeTimeout:          "Request timeout",
+	ErrCodeUnauthorized:     "Unauthorized",
+	ErrCodeForbidden:        "Forbidden",
+	ErrCodeTooManyRequests:  "Too many requests",
+	ErrCodeMessageTooLarge:  "Message too large",
+	ErrCodeRequestCancelled: "Request cancelled",
 }
 
 //-32000 to -32099 	RpcServer error 	Reserved for implementation-defined server-errors.
 
+// ErrorMapper converts errors returned by handlers to rpc errors.
+// It is called only for errors that are not rpc.Error already.
+type ErrorMapper func(err error) Error
+
+// defaultErrorMapper hides error details from client.
+func defaultErrorMapper(err error) Error {
+	return WrapError(err, "", ErrCodeInternalError)
+}
+
+// toError converts any error to rpc error. rpc.Error (even wrapped) is returned unchanged.
+func toError(err error, mapper ErrorMapper) Error {
+	var rpcErr Error
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	var rpcErrPtr *Error
+	if errors.As(err, &rpcErrPtr) && rpcErrPtr != nil {
+		return *rpcErrPtr
+	}
+	return mapper(err)
+}
+
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+	err     error
 }
 
 func (e Error) Error() string {
 	return fmt.Sprintf("jsonrpc2 error: code: %d message: %s", e.Code, e.Message)
 }
 
+// Unwrap returns wrapped cause of error (if any).
+func (e Error) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is rpc error with the same code.
+// So errors.Is(err, ErrorFromCode(ErrCodeMethodNotFound)) matches any "method not found" error.
+func (e Error) Is(target error) bool {
+	switch t := target.(type) {
+	case Error:
+		return t.Code == e.Code
+	case *Error:
+		return t != nil && t.Code == e.Code
+	}
+	return false
+}
+
+// WithData returns copy of error with additional information about error.
+func (e Error) WithData(data any) Error {
+	e.Data = data
+	return e
+}
+
 func ErrorFromCode(code int) Error {
 	if _, ok := errorMap[code]; ok {
 		return Error{
@@ -69,3 +132,27 @@ func NewError(message string, code int) Error {
 		Message: message,
 	}
 }
+
+func NewErrorWithData(message string, code int, data any) Error {
+	return NewError(message, code).WithData(data)
+}
+
+// WrapError returns rpc error with given code that wraps cause err.
+// If message is empty, it is taken from error code or cause.
+// Cause itself is never sent to client.
+func WrapError(err error, message string, code int) Error {
+	if code == 0 {
+		code = ErrUser
+	}
+	if message == "" {
+		message = errorMap[code]
+	}
+	if message == "" && err != nil {
+		message = err.Error()
+	}
+	return Error{
+		Code:    code,
+		Message: message,
+		err:     err,
+	}
+}
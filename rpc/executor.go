@@ -0,0 +1,96 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import "sync"
+
+// maxQueue is max number of jobs waiting for execution in sequential mode.
+const maxQueue = 128
+
+// executor runs jobs either in parallel or one by one in order they were added.
+// Adding job never blocks, so connection reader is not stalled by long running handler
+// (it must deliver responses to calls of handlers), but at most maxQueue jobs may wait.
+type executor struct {
+	parallel bool
+	wg       sync.WaitGroup
+	mu       sync.Mutex
+	cond     *sync.Cond // signalled when job is added or executor is closed
+	queue    []func()
+	closed   bool
+}
+
+func newExecutor(parallel bool) *executor {
+	e := &executor{parallel: parallel}
+	e.cond = sync.NewCond(&e.mu)
+	if !parallel {
+		e.wg.Add(1)
+		go e.loop()
+	}
+	return e
+}
+
+// run adds job. It returns false if queue is full, so job is not added.
+func (e *executor) run(job func()) bool {
+	if e.parallel {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			job()
+		}()
+		return true
+	}
+	e.mu.Lock()
+	if len(e.queue) >= maxQueue {
+		e.mu.Unlock()
+		return false
+	}
+	e.queue = append(e.queue, job)
+	e.mu.Unlock()
+	e.cond.Signal()
+	return true
+}
+
+// wait stops accepting new jobs and waits until all added jobs are done.
+func (e *executor) wait() {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+	e.cond.Signal()
+	e.wg.Wait()
+}
+
+func (e *executor) loop() {
+	defer e.wg.Done()
+	for {
+		e.mu.Lock()
+		for len(e.queue) == 0 && !e.closed {
+			e.cond.Wait()
+		}
+		if len(e.queue) == 0 {
+			e.mu.Unlock()
+			return
+		}
+		job := e.queue[0]
+		e.queue[0] = nil
+		e.queue = e.queue[1:]
+		e.mu.Unlock()
+		job()
+	}
+}
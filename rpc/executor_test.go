@@ -0,0 +1,60 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import "testing"
+
+func TestExecutorSequentialRejectsWhenFull(t *testing.T) {
+	e := newExecutor(false)
+	started, release := make(chan struct{}), make(chan struct{})
+	order := make(chan int, maxQueue+2)
+	// first job is executing, maxQueue jobs are waiting
+	for i := 0; i < maxQueue+1; i++ {
+		i := i
+		if !e.run(func() {
+			if i == 0 {
+				close(started)
+				<-release
+			}
+			order <- i
+		}) {
+			t.Fatalf("job %d rejected", i)
+		}
+		if i == 0 {
+			<-started
+		}
+	}
+	if e.run(func() { order <- -1 }) {
+		t.Fatal("job added to full queue")
+	}
+	close(release)
+	e.wait()
+	close(order)
+	next := 0
+	for i := range order {
+		if i != next {
+			t.Fatalf("job %d executed, want %d", i, next)
+		}
+		next++
+	}
+	if next != maxQueue+1 {
+		t.Fatalf("%d jobs executed, want %d", next, maxQueue+1)
+	}
+}
@@ -0,0 +1,173 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+// concurrencyRetryAfter is suggested delay before retry of request rejected by concurrency limit.
+const concurrencyRetryAfter = 100 * time.Millisecond
+
+// RetryAfterData is data of ErrCodeTooManyRequests error.
+type RetryAfterData struct {
+	RetryAfter float64 `json:"retryAfter"` // Seconds to wait before retry
+}
+
+// TooManyRequests returns ErrCodeTooManyRequests error telling client when to retry.
+func TooManyRequests(retryAfter time.Duration) Error {
+	seconds := math.Ceil(retryAfter.Seconds()*1000) / 1000
+	return ErrorFromCode(ErrCodeTooManyRequests).WithData(RetryAfterData{RetryAfter: seconds})
+}
+
+// acquire adds n to counter unless it exceeds limit. Zero limit means no limit.
+func acquire(counter *int64, n int64, limit int) bool {
+	if atomic.AddInt64(counter, n) > int64(limit) && limit > 0 {
+		atomic.AddInt64(counter, -n)
+		return false
+	}
+	return true
+}
+
+// messageCost returns number of requests in message, counted against concurrency limits.
+// Invalid and too large batches are rejected without executing requests, so they cost one.
+func (r *RpcServer) messageCost(msg json.RawMessage) int64 {
+	if !isBatch(msg) {
+		return 1
+	}
+	batch := []json.RawMessage{}
+	if json.Unmarshal(msg, &batch) != nil || len(batch) == 0 || (r.maxBatchSize > 0 && len(batch) > r.maxBatchSize) {
+		return 1
+	}
+	return int64(len(batch))
+}
+
+// rejectMessage returns error responses to requests of message, so they are not executed.
+// Notifications are dropped silently.
+func rejectMessage(msg json.RawMessage, err Error) any {
+	if !isBatch(msg) {
+		req := new(RpcRequest)
+		if json.Unmarshal(msg, req) != nil {
+			return ErrorResponse(nil, err)
+		}
+		if req.IsNotification() {
+			return nil
+		}
+		return ErrorResponse(req.Id, err)
+	}
+	batch := []json.RawMessage{}
+	if json.Unmarshal(msg, &batch) != nil {
+		return nil
+	}
+	responses := []*RpcResponse{}
+	for _, raw := range batch {
+		if resp, ok := rejectMessage(raw, err).(*RpcResponse); ok {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		return nil
+	}
+	return responses
+}
+
+// MaxMessageSize returns size limit of incoming messages, so transports stop reading message exceeding it.
+// It implements transport.MessageSizeLimiter.
+func (r *RpcServer) MaxMessageSize() int64 {
+	return r.maxMessageSize
+}
+
+// limitedReader fails with transport.ErrMessageTooLarge when message starting at offset start exceeds limit.
+type limitedReader struct {
+	rd    io.Reader
+	read  int64
+	start int64
+	limit int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	left := l.start + l.limit - l.read
+	if left <= 0 {
+		return 0, transport.ErrMessageTooLarge
+	}
+	if int64(len(p)) > left {
+		p = p[:left]
+	}
+	n, err := l.rd.Read(p)
+	l.read += int64(n)
+	return n, err
+}
+
+// checkDepth reports whether message has arrays or objects nested deeper than limit, and returns response to it.
+// Request object is level 1, also in batch.
+func (r *RpcServer) checkDepth(msg json.RawMessage) (any, bool) {
+	if r.maxDepth <= 0 {
+		return nil, false
+	}
+	limit := r.maxDepth
+	if isBatch(msg) {
+		limit++
+	}
+	if !exceedsDepth(msg, limit) {
+		return nil, false
+	}
+	err := NewError("Message nesting too deep", ErrCodeMessageTooLarge)
+	if isBatch(msg) {
+		return ErrorResponse(nil, err), true
+	}
+	return rejectMessage(msg, err), true
+}
+
+// exceedsDepth reports whether arrays and objects of JSON value are nested deeper than limit.
+func exceedsDepth(msg json.RawMessage, limit int) bool {
+	depth := 0
+	inString, escaped := false, false
+	for _, c := range msg {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '[', '{':
+			depth++
+			if depth > limit {
+				return true
+			}
+		case ']', '}':
+			depth--
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+type testResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	Id     json.RawMessage `json:"id"`
+}
+
+func TestConcurrencyLimitCountsBatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []Option
+		batch int
+		// number of rejected requests of batch while one request is executing
+		wantRejected int
+	}{
+		{"no limit", nil, 3, 0},
+		{"batch fits", []Option{WithMaxConcurrency(3)}, 2, 0},
+		{"batch doesn't fit", []Option{WithMaxConcurrency(3)}, 3, 3},
+		{"batch doesn't fit connection limit", []Option{WithMaxConnConcurrency(3)}, 3, 3},
+		{"batch longer than limit", []Option{WithMaxConcurrency(2)}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.opts...)
+			release := make(chan struct{})
+			if err := s.Register("wait", H(func(ctx context.Context, _ *struct{}) (int, error) {
+				<-release
+				return 0, nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Register("ping", H(func(ctx context.Context, _ *struct{}) (int, error) {
+				return 1, nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+			inR, inW := io.Pipe()
+			outR, outW := io.Pipe()
+			go func() {
+				s.Resolve(context.Background(), inR, outW, true)
+				outW.Close()
+			}()
+			dec := json.NewDecoder(outR)
+
+			io.WriteString(inW, `{"jsonrpc":"2.0","method":"wait","id":0}`)
+			batch := []map[string]any{}
+			for i := 1; i <= tt.batch; i++ {
+				batch = append(batch, map[string]any{"jsonrpc": "2.0", "method": "ping", "id": i})
+			}
+			b, _ := json.Marshal(batch)
+			inW.Write(b)
+
+			// "wait" is executing until batch response is read
+			responses := []testResponse{}
+			if err := dec.Decode(&responses); err != nil {
+				t.Fatal(err)
+			}
+			close(release)
+			rejected := 0
+			for _, resp := range responses {
+				if resp.Error != nil && resp.Error.Code == ErrCodeTooManyRequests {
+					rejected++
+				}
+			}
+			if len(responses) != tt.batch || rejected != tt.wantRejected {
+				t.Fatalf("%d responses with %d rejected, want %d with %d rejected", len(responses), rejected, tt.batch, tt.wantRejected)
+			}
+			resp := testResponse{}
+			if err := dec.Decode(&resp); err != nil || resp.Error != nil {
+				t.Fatalf("wait response error = %v, %v", err, resp.Error)
+			}
+			inW.Close()
+		})
+	}
+}
+
+// summarize describes responses as "id:code" (code is 0 for results), batch responses are in brackets.
+// Responses are sorted, because rejected messages are answered by reader before queued ones.
+func summarize(t *testing.T, out []byte) []string {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(out))
+	summary := []string{}
+	describe := func(resp testResponse) string {
+		code := 0
+		if resp.Error != nil {
+			code = resp.Error.Code
+		}
+		return fmt.Sprintf("%s:%d", resp.Id, code)
+	}
+	for dec.More() {
+		raw := json.RawMessage{}
+		if err := dec.Decode(&raw); err != nil {
+			t.Fatalf("invalid output %q: %v", out, err)
+		}
+		if isBatch(raw) {
+			batch := []testResponse{}
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				t.Fatal(err)
+			}
+			items := []string{}
+			for _, resp := range batch {
+				items = append(items, describe(resp))
+			}
+			summary = append(summary, "["+strings.Join(items, " ")+"]")
+			continue
+		}
+		resp := testResponse{}
+		if err := json.Unmarshal(raw, &resp); err != nil {
+			t.Fatal(err)
+		}
+		summary = append(summary, describe(resp))
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestMessageLimits(t *testing.T) {
+	long := `{"jsonrpc":"2.0","method":"ping","params":{"s":"` + strings.Repeat("x", 100) + `"},"id":9}`
+	tests := []struct {
+		name   string
+		opts   []Option
+		framed bool // newline framing, otherwise stream of JSON values
+		input  string
+		want   []string
+	}{
+		{
+			name:  "batch",
+			opts:  []Option{WithMaxBatchSize(2)},
+			input: `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"ping","id":2}]`,
+			want:  []string{"null:-32005"},
+		},
+		{
+			name:  "batch within limit",
+			opts:  []Option{WithMaxBatchSize(2)},
+			input: `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"nope","id":2}]`,
+			want:  []string{"[1:0 2:-32601]"},
+		},
+		{
+			name:  "empty batch",
+			input: `[]`,
+			want:  []string{"null:-32600"},
+		},
+		{
+			name:  "depth",
+			opts:  []Option{WithMaxDepth(2)},
+			input: `{"jsonrpc":"2.0","method":"ping","params":{"s":"{[["},"id":1}{"jsonrpc":"2.0","method":"ping","params":[[1]],"id":2}`,
+			want:  []string{"1:0", "2:-32005"},
+		},
+		{
+			name:  "depth of batch",
+			opts:  []Option{WithMaxDepth(2)},
+			input: `[{"jsonrpc":"2.0","method":"ping","params":{},"id":1}][{"jsonrpc":"2.0","method":"ping","params":{"s":{}},"id":2}]`,
+			want:  []string{"[1:0]", "null:-32005"},
+		},
+		{
+			name:   "message size of frame",
+			opts:   []Option{WithMaxMessageSize(64)},
+			framed: true,
+			input:  `{"jsonrpc":"2.0","method":"ping","id":1}` + "\n" + long + "\n" + `{"jsonrpc":"2.0","method":"ping","id":2}` + "\n",
+			want:   []string{"1:0", "null:-32005", "2:0"},
+		},
+		{
+			name:  "message size of stream",
+			opts:  []Option{WithMaxMessageSize(64)},
+			input: `{"jsonrpc":"2.0","method":"ping","id":1}` + long + `{"jsonrpc":"2.0","method":"ping","id":2}`,
+			want:  []string{"1:0", "null:-32005"},
+		},
+		{
+			name:   "invalid frame",
+			framed: true,
+			input:  `{"jsonrpc":"2.0","method":"ping","id":1}` + "\n{\n" + `{"jsonrpc":"2.0","method":"ping","id":2}` + "\n",
+			want:   []string{"1:0", "null:-32700", "2:0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.opts...)
+			if err := s.Register("ping", H(func(ctx context.Context, p *struct {
+				S any `json:"s"`
+			}) (int, error) {
+				return 1, nil
+			})); err != nil {
+				t.Fatal(err)
+			}
+			out := &bytes.Buffer{}
+			if tt.framed {
+				framer := transport.NewlineFramer{}
+				s.ResolveFrames(context.Background(), framer.NewReader(strings.NewReader(tt.input)), framer.NewWriter(out), false)
+			} else {
+				s.Resolve(context.Background(), strings.NewReader(tt.input), out, false)
+			}
+			sort.Strings(tt.want)
+			if got := summarize(t, out.Bytes()); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMessageLimitHTTP(t *testing.T) {
+	s := New(WithMaxMessageSize(64))
+	if err := s.Register("ping", H(func(ctx context.Context, _ *struct{}) (int, error) { return 1, nil })); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		want       []string
+	}{
+		{"small", `{"jsonrpc":"2.0","method":"ping","id":1}`, http.StatusOK, []string{"1:0"}},
+		{"large", `{"jsonrpc":"2.0","method":"ping","id":1,"pad":"` + strings.Repeat("x", 64) + `"}`, http.StatusRequestEntityTooLarge, []string{"null:-32005"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := summarize(t, w.Body.Bytes()); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
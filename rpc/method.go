@@ -0,0 +1,135 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// MethodInfo describes registered method.
+type MethodInfo struct {
+	Name         string          // Name of method as registered (with group prefix)
+	Description  string          // Optional human readable description
+	ParamsSchema json.RawMessage // JSON Schema of params (generated from ParamsType if not set by MethodSchema)
+	ResultSchema json.RawMessage // JSON Schema of result (generated from ResultType if not set by MethodSchema)
+	ParamsType   reflect.Type    // Go type of params if handler is TypedHandler (nil if method has no params)
+	ParamNames   []string        // Names of params in order if params are struct, positional params are accepted too
+	ResultType   reflect.Type    // Go type of result if handler is TypedHandler
+}
+
+// Methods returns info of registered methods, including methods of mounted servers, sorted by name.
+func (r *RpcServer) Methods() []MethodInfo {
+	r.mu.RLock()
+	methods := make([]MethodInfo, 0, len(r.methods))
+	for _, m := range r.methods {
+		methods = append(methods, m.info)
+	}
+	mounts := append([]mount(nil), r.mounts...)
+	r.mu.RUnlock()
+	for _, mnt := range mounts {
+		for _, info := range mnt.server.Methods() {
+			info.Name = joinMethod(mnt.prefix, info.Name)
+			methods = append(methods, info)
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool {
+		return methods[i].Name < methods[j].Name
+	})
+	return methods
+}
+
+type methodInfoKey struct{}
+
+// MethodInfoFromContext returns info of method that is called. It is available in all middlewares
+// if method exists. For method of mounted server, middlewares of outer server see its name with mount prefix.
+func MethodInfoFromContext(ctx context.Context) (MethodInfo, bool) {
+	info, ok := ctx.Value(methodInfoKey{}).(MethodInfo)
+	return info, ok
+}
+
+// MethodOption configures single method on registration.
+type MethodOption func(m *method)
+
+// MethodMiddleware adds middlewares that run only for this method, after server and group middlewares.
+func MethodMiddleware(middlewares ...Middleware) MethodOption {
+	return func(m *method) {
+		m.middlewares = append(m.middlewares, middlewares...)
+	}
+}
+
+// MethodTimeout sets timeout of method overriding server timeouts.
+func MethodTimeout(d time.Duration) MethodOption {
+	return func(m *method) {
+		m.timeout = &d
+	}
+}
+
+// MethodDescription sets human readable description of method.
+func MethodDescription(description string) MethodOption {
+	return func(m *method) {
+		m.info.Description = description
+	}
+}
+
+// MethodSchema sets JSON Schemas of params and result overriding schemas generated from Go types.
+// Nil schema keeps generated one. Schemas are validated by middleware.Validation(nil).
+func MethodSchema(params, result json.RawMessage) MethodOption {
+	return func(m *method) {
+		if params != nil {
+			m.info.ParamsSchema = params
+		}
+		if result != nil {
+			m.info.ResultSchema = result
+		}
+	}
+}
+
+// SkipMiddlewares disables server and group middlewares for method (e.g. health check).
+// Middlewares of method itself still run.
+func SkipMiddlewares() MethodOption {
+	return func(m *method) {
+		m.skipMiddlewares = true
+	}
+}
+
+// method is registered handler.
+type method struct {
+	info            MethodInfo
+	handler         Handler
+	group           *Group // nil for methods registered on server directly
+	middlewares     []Middleware
+	timeout         *time.Duration
+	skipMiddlewares bool
+}
+
+// wrap applies middlewares of method to handler.
+func (m *method) wrap(h RpcHandler) RpcHandler {
+	for _, mw := range m.middlewares {
+		h = mw(h)
+	}
+	if !m.skipMiddlewares {
+		h = m.group.wrap(h)
+	}
+	return h
+}
@@ -0,0 +1,111 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMethodMiddlewares(t *testing.T) {
+	trace := []string{}
+	mark := func(name string) Middleware {
+		return func(next RpcHandler) RpcHandler {
+			return func(ctx context.Context, req *RpcRequest) *RpcResponse {
+				trace = append(trace, name)
+				return next(ctx, req)
+			}
+		}
+	}
+	s := New(WithMiddleware(mark("server")))
+	api := s.Group("api", mark("group"))
+	api.Register("plain", methodName)
+	api.Register("wrapped", methodName, MethodMiddleware(mark("first"), mark("second")))
+	api.Register("health", methodName, SkipMiddlewares(), MethodMiddleware(mark("own")))
+
+	tests := []struct {
+		method    string
+		wantTrace []string
+	}{
+		{"api.plain", []string{"server", "group"}},
+		{"api.wrapped", []string{"server", "group", "second", "first"}},
+		{"api.health", []string{"own"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			trace = []string{}
+			if got, code := call(t, s, tt.method); got != tt.method || code != 0 {
+				t.Fatalf("got %q:%d, want %q", got, code, tt.method)
+			}
+			if !reflect.DeepEqual(trace, tt.wantTrace) {
+				t.Fatalf("middlewares %v, want %v", trace, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestMethodTimeout(t *testing.T) {
+	s := New(WithTimeout(10 * time.Millisecond))
+	s.Register("unlimited", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, 50*time.Millisecond)
+	}), MethodTimeout(0))
+	s.Register("limited", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		return wait(ctx, 50*time.Millisecond)
+	}))
+	if got := resolve(t, s, `{"jsonrpc":"2.0","method":"unlimited","id":1}`, false); !reflect.DeepEqual(got, []string{"1:0"}) {
+		t.Fatalf("zero method timeout: got %v, want [1:0]", got)
+	}
+	if got := resolve(t, s, `{"jsonrpc":"2.0","method":"limited","id":1}`, false); !reflect.DeepEqual(got, []string{"1:-32001"}) {
+		t.Fatalf("server timeout: got %v, want [1:-32001]", got)
+	}
+}
+
+type pointParams struct {
+	X int `json:"x"`
+}
+
+func TestMethodInfo(t *testing.T) {
+	paramsSchema := json.RawMessage(`{"type":"object","required":["x"]}`)
+	s := New()
+	s.Register("point", H(func(ctx context.Context, p *pointParams) (int, error) { return p.X, nil }),
+		MethodDescription("Returns x"), MethodSchema(paramsSchema, nil))
+	s.Register("generated", H(func(ctx context.Context, p *pointParams) (int, error) { return p.X, nil }))
+
+	methods := s.Methods()
+	if len(methods) != 2 {
+		t.Fatalf("%d methods, want 2", len(methods))
+	}
+	generated, point := methods[0], methods[1]
+	if point.Description != "Returns x" || generated.Description != "" {
+		t.Fatalf("descriptions %q and %q", point.Description, generated.Description)
+	}
+	if string(point.ParamsSchema) != string(paramsSchema) {
+		t.Fatalf("params schema %s, want %s", point.ParamsSchema, paramsSchema)
+	}
+	if string(point.ResultSchema) != string(generated.ResultSchema) || len(point.ResultSchema) == 0 {
+		t.Fatalf("result schema %s, want generated %s", point.ResultSchema, generated.ResultSchema)
+	}
+	if string(generated.ParamsSchema) == string(paramsSchema) || len(generated.ParamsSchema) == 0 {
+		t.Fatalf("generated params schema %s", generated.ParamsSchema)
+	}
+}
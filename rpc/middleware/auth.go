@@ -0,0 +1,264 @@
+//Package middleware provides middlewares for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+// ErrNoCredentials is returned by Authenticator if request has no credentials it checks,
+// so next authenticator is tried.
+var ErrNoCredentials = errors.New("no credentials")
+
+// Principal is authenticated caller.
+type Principal struct {
+	ID     string
+	Roles  []string
+	Claims map[string]any // Optional additional info (e.g. token claims)
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns caller authenticated by Auth middleware.
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+// Authenticator identifies caller of request. Request metadata is available by transport.MetadataFromContext.
+// It returns ErrNoCredentials if request has no credentials of its kind, or other error if they are invalid.
+type Authenticator interface {
+	Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error)
+}
+
+type AuthenticatorFunc func(ctx context.Context, req *rpc.RpcRequest) (*Principal, error)
+
+func (f AuthenticatorFunc) Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error) {
+	return f(ctx, req)
+}
+
+type AuthOptions struct {
+	Authenticators []Authenticator     // Authenticators tried in order until one recognizes credentials
+	Public         []string            // Optional methods callable without authentication
+	Allow          map[string][]string // Optional IDs or roles of principals allowed to call method (others are forbidden)
+	ErrorCode      int                 // Optional code of error for unauthenticated calls (default rpc.ErrCodeUnauthorized)
+	ForbiddenCode  int                 // Optional code of error for not allowed calls (default rpc.ErrCodeForbidden)
+}
+
+// Auth authenticates calls and puts principal to context. Calls without valid credentials are rejected,
+// unless method is public. Methods are matched by name as registered (see rpc.MethodInfo), not as called,
+// so case variants of name can't bypass checks. Calls of unknown methods are never public,
+// and forbidden if Allow is set.
+func Auth(opts AuthOptions) rpc.Middleware {
+	if opts.ErrorCode == 0 {
+		opts.ErrorCode = rpc.ErrCodeUnauthorized
+	}
+	if opts.ForbiddenCode == 0 {
+		opts.ForbiddenCode = rpc.ErrCodeForbidden
+	}
+	public := map[string]bool{}
+	for _, method := range opts.Public {
+		public[method] = true
+	}
+	return func(handler rpc.RpcHandler) rpc.RpcHandler {
+		return func(ctx context.Context, req *rpc.RpcRequest) *rpc.RpcResponse {
+			info, known := rpc.MethodInfoFromContext(ctx)
+			principal, err := authenticate(ctx, req, opts.Authenticators)
+			if err != nil {
+				if known && public[info.Name] {
+					return handler(ctx, req)
+				}
+				return rpc.ErrorResponse(req.Id, rpc.WrapError(err, "", opts.ErrorCode))
+			}
+			allowed, restricted := opts.Allow[info.Name]
+			if !known {
+				restricted = len(opts.Allow) > 0
+			}
+			if restricted && !principal.matches(allowed) {
+				return rpc.ErrorResponse(req.Id, rpc.ErrorFromCode(opts.ForbiddenCode))
+			}
+			return handler(context.WithValue(ctx, principalKey{}, principal), req)
+		}
+	}
+}
+
+func authenticate(ctx context.Context, req *rpc.RpcRequest, authenticators []Authenticator) (*Principal, error) {
+	for _, a := range authenticators {
+		p, err := a.Authenticate(ctx, req)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, errors.New("authenticator returned no principal")
+		}
+		return p, nil
+	}
+	return nil, ErrNoCredentials
+}
+
+// matches reports whether principal has ID or role from list.
+func (p *Principal) matches(allowed []string) bool {
+	for _, a := range allowed {
+		if a == p.ID {
+			return true
+		}
+		for _, role := range p.Roles {
+			if a == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BearerAuth authenticates by "Authorization: Bearer <token>" header of HTTP request or WebSocket handshake.
+type BearerAuth struct {
+	Validate func(ctx context.Context, token string) (*Principal, error)
+}
+
+func (a BearerAuth) Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error) {
+	md, ok := transport.MetadataFromContext(ctx)
+	if !ok || md.Header == nil {
+		return nil, ErrNoCredentials
+	}
+	scheme, token, found := strings.Cut(md.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.Validate(ctx, token)
+}
+
+// APIKeyAuth authenticates by API key passed in header of HTTP request or WebSocket handshake.
+type APIKeyAuth struct {
+	Header string                                                    // Optional header name (default X-API-Key)
+	Lookup func(ctx context.Context, key string) (*Principal, error) // Returns principal owning key
+}
+
+// StaticAPIKeys returns lookup function for fixed set of keys. Keys are compared in constant time.
+func StaticAPIKeys(keys map[string]*Principal) func(ctx context.Context, key string) (*Principal, error) {
+	return func(ctx context.Context, key string) (*Principal, error) {
+		for k, p := range keys {
+			if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+				return p, nil
+			}
+		}
+		return nil, errors.New("unknown api key")
+	}
+}
+
+func (a APIKeyAuth) Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error) {
+	header := a.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	md, ok := transport.MetadataFromContext(ctx)
+	if !ok || md.Header == nil || md.Header.Get(header) == "" {
+		return nil, ErrNoCredentials
+	}
+	return a.Lookup(ctx, md.Header.Get(header))
+}
+
+// HMACAuth authenticates HTTP requests signed by shared secret. Client sends headers:
+//
+//	X-Key-Id: <key id>
+//	X-Timestamp: <unix seconds>
+//	X-Signature: <hex of HMAC-SHA256(secret, timestamp + "\n" + body)>
+type HMACAuth struct {
+	Secret          func(ctx context.Context, keyID string) ([]byte, *Principal, error) // Returns secret and owner of key
+	KeyHeader       string                                                              // Optional (default X-Key-Id)
+	TimestampHeader string                                                              // Optional (default X-Timestamp)
+	SignatureHeader string                                                              // Optional (default X-Signature)
+	MaxSkew         time.Duration                                                       // Optional max age of signature (default 5 minutes)
+}
+
+func (a HMACAuth) Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error) {
+	keyHeader, tsHeader, sigHeader, maxSkew := a.KeyHeader, a.TimestampHeader, a.SignatureHeader, a.MaxSkew
+	if keyHeader == "" {
+		keyHeader = "X-Key-Id"
+	}
+	if tsHeader == "" {
+		tsHeader = "X-Timestamp"
+	}
+	if sigHeader == "" {
+		sigHeader = "X-Signature"
+	}
+	if maxSkew == 0 {
+		maxSkew = 5 * time.Minute
+	}
+	md, ok := transport.MetadataFromContext(ctx)
+	if !ok || md.Header == nil || md.Header.Get(sigHeader) == "" {
+		return nil, ErrNoCredentials
+	}
+	ts, err := strconv.ParseInt(md.Header.Get(tsHeader), 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid signature timestamp")
+	}
+	if skew := time.Since(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+		return nil, errors.New("signature expired")
+	}
+	signature, err := hex.DecodeString(md.Header.Get(sigHeader))
+	if err != nil {
+		return nil, errors.New("invalid signature")
+	}
+	secret, principal, err := a.Secret(ctx, md.Header.Get(keyHeader))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(md.Header.Get(tsHeader)))
+	mac.Write([]byte("\n"))
+	mac.Write(md.Body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errors.New("invalid signature")
+	}
+	return principal, nil
+}
+
+// MTLSAuth authenticates by verified client certificate of TLS connection.
+type MTLSAuth struct {
+	// Optional function returning principal of certificate (default principal with ID of certificate common name)
+	Principal func(ctx context.Context, cert *x509.Certificate) (*Principal, error)
+}
+
+func (a MTLSAuth) Authenticate(ctx context.Context, req *rpc.RpcRequest) (*Principal, error) {
+	cert := transport.ClientCertificate(ctx)
+	if cert == nil {
+		return nil, ErrNoCredentials
+	}
+	if a.Principal == nil {
+		return &Principal{ID: cert.Subject.CommonName}, nil
+	}
+	return a.Principal(ctx, cert)
+}
@@ -0,0 +1,170 @@
+//Package middleware provides middlewares for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+type noParams struct{}
+
+// whoami returns ID of principal or empty string.
+func whoami(ctx context.Context, _ *noParams) (string, error) {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return p.ID, nil
+	}
+	return "", nil
+}
+
+type result struct {
+	Result string     `json:"result"`
+	Error  *rpc.Error `json:"error"`
+}
+
+// call resolves single request with given transport metadata.
+func call(t *testing.T, s *rpc.RpcServer, md *transport.Metadata, method string) result {
+	t.Helper()
+	msg := []byte(`{"jsonrpc":"2.0","method":"` + method + `","id":1}`)
+	md.Body = msg
+	out := &bytes.Buffer{}
+	s.Resolve(transport.WithMetadata(context.Background(), md), bytes.NewReader(msg), out, false)
+	res := result{}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", out, err)
+	}
+	return res
+}
+
+func TestAuth(t *testing.T) {
+	billing := rpc.New()
+	if err := billing.Register("charge", rpc.H(whoami)); err != nil {
+		t.Fatal(err)
+	}
+	s := rpc.New()
+	for _, method := range []string{"ping", "profile", "admin.delete"} {
+		if err := s.Register(method, rpc.H(whoami)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Mount("billing", billing)
+	s.Use(rpc.WithMiddleware(Auth(AuthOptions{
+		Authenticators: []Authenticator{
+			BearerAuth{Validate: func(ctx context.Context, token string) (*Principal, error) {
+				switch token {
+				case "alice":
+					return &Principal{ID: "alice", Roles: []string{"admin"}}, nil
+				case "bob":
+					return &Principal{ID: "bob"}, nil
+				}
+				return nil, errors.New("invalid token")
+			}},
+			APIKeyAuth{Lookup: StaticAPIKeys(map[string]*Principal{"key": {ID: "svc", Roles: []string{"billing"}}})},
+			HMACAuth{Secret: func(ctx context.Context, keyID string) ([]byte, *Principal, error) {
+				if keyID != "k1" {
+					return nil, nil, errors.New("unknown key")
+				}
+				return []byte("secret"), &Principal{ID: "signer"}, nil
+			}},
+			MTLSAuth{},
+		},
+		Public: []string{"ping"},
+		Allow: map[string][]string{
+			"admin.delete":   {"admin"},
+			"billing.charge": {"billing"},
+		},
+	})))
+
+	bearer := func(token string) http.Header {
+		return http.Header{"Authorization": {"Bearer " + token}}
+	}
+	signed := func(secret, method string) http.Header {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(ts + "\n" + `{"jsonrpc":"2.0","method":"` + method + `","id":1}`))
+		return http.Header{
+			"X-Key-Id":    {"k1"},
+			"X-Timestamp": {ts},
+			"X-Signature": {hex.EncodeToString(mac.Sum(nil))},
+		}
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client"}}
+	verified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+	unverified := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+
+	tests := []struct {
+		name     string
+		header   http.Header
+		tls      *tls.ConnectionState
+		method   string
+		wantCode int // 0 if call succeeds
+		wantID   string
+	}{
+		{name: "anonymous public", method: "ping"},
+		{name: "anonymous public in other case", method: "PING"},
+		{name: "anonymous", method: "profile", wantCode: rpc.ErrCodeUnauthorized},
+		{name: "anonymous unknown method", method: "nope", wantCode: rpc.ErrCodeUnauthorized},
+		{name: "invalid token", header: bearer("eve"), method: "profile", wantCode: rpc.ErrCodeUnauthorized},
+		{name: "invalid token on public", header: bearer("eve"), method: "ping"},
+		{name: "bearer", header: bearer("bob"), method: "profile", wantID: "bob"},
+		{name: "bearer on public", header: bearer("bob"), method: "ping", wantID: "bob"},
+		{name: "not allowed", header: bearer("bob"), method: "admin.delete", wantCode: rpc.ErrCodeForbidden},
+		{name: "not allowed in other case", header: bearer("bob"), method: "ADMIN.delete", wantCode: rpc.ErrCodeForbidden},
+		{name: "allowed by role", header: bearer("alice"), method: "Admin.Delete", wantID: "alice"},
+		{name: "unknown method with allow list", header: bearer("bob"), method: "nope", wantCode: rpc.ErrCodeForbidden},
+		{name: "api key on mounted method", header: http.Header{"X-Api-Key": {"key"}}, method: "billing.charge", wantID: "svc"},
+		{name: "mounted method not allowed", header: bearer("bob"), method: "BILLING.charge", wantCode: rpc.ErrCodeForbidden},
+		{name: "invalid api key", header: http.Header{"X-Api-Key": {"nokey"}}, method: "profile", wantCode: rpc.ErrCodeUnauthorized},
+		{name: "hmac", header: signed("secret", "profile"), method: "profile", wantID: "signer"},
+		{name: "hmac invalid signature", header: signed("other", "profile"), method: "profile", wantCode: rpc.ErrCodeUnauthorized},
+		{name: "mtls", tls: verified, method: "profile", wantID: "client"},
+		{name: "mtls not verified", tls: unverified, method: "profile", wantCode: rpc.ErrCodeUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := call(t, s, &transport.Metadata{Header: tt.header, TLS: tt.tls}, tt.method)
+			code := 0
+			if res.Error != nil {
+				code = res.Error.Code
+			}
+			if code != tt.wantCode {
+				t.Fatalf("error = %v, want code %d", res.Error, tt.wantCode)
+			}
+			if res.Result != tt.wantID {
+				t.Fatalf("principal = %q, want %q", res.Result, tt.wantID)
+			}
+		})
+	}
+}
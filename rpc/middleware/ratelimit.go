@@ -0,0 +1,174 @@
+//Package middleware provides middlewares for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"context"
+	"math"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+// KeyFunc returns key of request. Requests with the same key share rate limit.
+type KeyFunc func(ctx context.Context, req *rpc.RpcRequest) string
+
+// ByMethod limits calls of each method regardless of caller. Unknown methods share one limit.
+func ByMethod(ctx context.Context, req *rpc.RpcRequest) string {
+	return methodName(ctx)
+}
+
+// methodName returns name of called method as registered, so calls with other case of name are the same method.
+// It is empty for unknown methods.
+func methodName(ctx context.Context) string {
+	info, _ := rpc.MethodInfoFromContext(ctx)
+	return info.Name
+}
+
+// ByPeer limits calls of each client host (uid of process for unix sockets).
+func ByPeer(ctx context.Context, req *rpc.RpcRequest) string {
+	md, ok := transport.MetadataFromContext(ctx)
+	if !ok {
+		return ""
+	}
+	if md.Peer != nil {
+		return "uid:" + strconv.Itoa(md.Peer.UID)
+	}
+	if host, _, err := net.SplitHostPort(md.RemoteAddr); err == nil {
+		return host
+	}
+	return md.RemoteAddr
+}
+
+// ByPrincipal limits calls of each principal authenticated by Auth middleware.
+// Anonymous calls are limited by peer.
+func ByPrincipal(ctx context.Context, req *rpc.RpcRequest) string {
+	if p, ok := PrincipalFromContext(ctx); ok {
+		return "principal:" + p.ID
+	}
+	return ByPeer(ctx, req)
+}
+
+type RateLimitOptions struct {
+	Rate    float64  // Allowed requests per second
+	Burst   int      // Optional number of requests allowed at once (default Rate rounded up)
+	Key     KeyFunc  // Optional key of request (default ByPeer)
+	Methods []string // Optional limited methods as registered (default all)
+}
+
+// RateLimit limits rate of calls by token bucket per key. Calls over limit are rejected
+// with rpc.ErrCodeTooManyRequests error, its data tells when to retry.
+// To limit by principal, it must be added before Auth, because middlewares added later are executed earlier.
+func RateLimit(opts RateLimitOptions) rpc.Middleware {
+	if opts.Burst <= 0 {
+		opts.Burst = int(math.Ceil(opts.Rate))
+		if opts.Burst < 1 {
+			opts.Burst = 1
+		}
+	}
+	if opts.Key == nil {
+		opts.Key = ByPeer
+	}
+	var methods map[string]bool
+	if opts.Methods != nil {
+		methods = map[string]bool{}
+		for _, method := range opts.Methods {
+			methods[method] = true
+		}
+	}
+	limiter := &rateLimiter{
+		rate:    opts.Rate,
+		burst:   float64(opts.Burst),
+		buckets: map[string]*bucket{},
+	}
+	return func(handler rpc.RpcHandler) rpc.RpcHandler {
+		return func(ctx context.Context, req *rpc.RpcRequest) *rpc.RpcResponse {
+			if methods != nil && !methods[methodName(ctx)] {
+				return handler(ctx, req)
+			}
+			if wait := limiter.take(opts.Key(ctx, req), time.Now()); wait > 0 {
+				return rpc.ErrorResponse(req.Id, rpc.TooManyRequests(wait))
+			}
+			return handler(ctx, req)
+		}
+	}
+}
+
+// sweepInterval is how often buckets refilled completely are forgotten.
+const sweepInterval = time.Minute
+
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64
+	burst     float64
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// take takes token from bucket of key. Returns zero on success or time until token is available.
+func (l *rateLimiter) take(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.lastSweep) > sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	if l.rate <= 0 {
+		return sweepInterval
+	}
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+func (l *rateLimiter) refill(b *bucket, now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+}
+
+// sweep removes full buckets, they are the same as new ones.
+func (l *rateLimiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
@@ -0,0 +1,124 @@
+//Package middleware provides middlewares for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     RateLimitOptions
+		calls    []string
+		wantCode []int // code of error of each call, 0 if call succeeds
+	}{
+		{
+			name:     "burst",
+			opts:     RateLimitOptions{Rate: 0.001, Burst: 2},
+			calls:    []string{"ping", "profile", "ping"},
+			wantCode: []int{0, 0, rpc.ErrCodeTooManyRequests},
+		},
+		{
+			name:     "only listed methods",
+			opts:     RateLimitOptions{Rate: 0.001, Burst: 1, Methods: []string{"profile"}},
+			calls:    []string{"profile", "ping", "ping", "profile"},
+			wantCode: []int{0, 0, 0, rpc.ErrCodeTooManyRequests},
+		},
+		{
+			name:     "listed method in other case",
+			opts:     RateLimitOptions{Rate: 0.001, Burst: 1, Methods: []string{"profile"}},
+			calls:    []string{"profile", "PROFILE", "Profile"},
+			wantCode: []int{0, rpc.ErrCodeTooManyRequests, rpc.ErrCodeTooManyRequests},
+		},
+		{
+			name:     "by method",
+			opts:     RateLimitOptions{Rate: 0.001, Burst: 1, Key: ByMethod},
+			calls:    []string{"profile", "ping", "PROFILE"},
+			wantCode: []int{0, 0, rpc.ErrCodeTooManyRequests},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := rpc.New()
+			for _, method := range []string{"ping", "profile"} {
+				if err := s.Register(method, rpc.H(whoami)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			s.Use(rpc.WithMiddleware(RateLimit(tt.opts)))
+			for i, method := range tt.calls {
+				res := call(t, s, &transport.Metadata{RemoteAddr: "10.0.0.1:1234"}, method)
+				code := 0
+				if res.Error != nil {
+					code = res.Error.Code
+				}
+				if code != tt.wantCode[i] {
+					t.Fatalf("call %d (%s): error = %v, want code %d", i, method, res.Error, tt.wantCode[i])
+				}
+				if code != rpc.ErrCodeTooManyRequests {
+					continue
+				}
+				data := rpc.RetryAfterData{}
+				if b, err := json.Marshal(res.Error.Data); err != nil || json.Unmarshal(b, &data) != nil || data.RetryAfter <= 0 {
+					t.Fatalf("call %d: invalid retry data %v", i, res.Error.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterRefill(t *testing.T) {
+	l := &rateLimiter{rate: 2, burst: 2, buckets: map[string]*bucket{}}
+	now := time.Now()
+	steps := []struct {
+		after    time.Duration
+		key      string
+		wantWait time.Duration
+	}{
+		{0, "a", 0},
+		{0, "a", 0},
+		{0, "a", 500 * time.Millisecond},
+		{0, "b", 0},
+		{250 * time.Millisecond, "a", 250 * time.Millisecond},
+		{250 * time.Millisecond, "a", 0},
+		{10 * time.Second, "a", 0},
+		{0, "a", 0},
+		{0, "a", 500 * time.Millisecond},
+	}
+	for i, step := range steps {
+		now = now.Add(step.after)
+		if wait := l.take(step.key, now); wait != step.wantWait {
+			t.Fatalf("step %d: wait = %v, want %v", i, wait, step.wantWait)
+		}
+	}
+	if len(l.buckets) != 2 {
+		t.Fatalf("%d buckets, want 2", len(l.buckets))
+	}
+	l.take("a", now.Add(2*sweepInterval))
+	if len(l.buckets) != 1 {
+		t.Fatalf("%d buckets after sweep, want 1", len(l.buckets))
+	}
+}
@@ -20,10 +20,11 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/qri-io/jsonschema"
 
@@ -40,22 +41,59 @@ func MustSchema(schema string) ServiceSchema {
 	return *ss
 }
 
+// ValidationError describes single schema violation. List of them is passed in error data.
+type ValidationError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
 type MethodSchema struct {
 	Request  *jsonschema.Schema `json:"request"`
 	Response *jsonschema.Schema `json:"response"`
 }
 
+// Validation validates params and results of methods. Method is looked up in serviceSchema by registered name,
+// then by requested name and by requested name in lower case. Methods missing in serviceSchema are not validated.
+// If serviceSchema is nil, all methods are validated by schemas of rpc.MethodInfo instead:
+// generated from Go types of handlers made by rpc.H and rpc.HS or set by rpc.MethodSchema.
 func Validation(serviceSchema ServiceSchema) (rpc.Middleware, error) {
+	compiled := &schemaCache{}
 	return func(handler rpc.RpcHandler) rpc.RpcHandler {
 		return func(ctx context.Context, req *rpc.RpcRequest) *rpc.RpcResponse {
-			rs, hasSchema := serviceSchema[strings.ToLower(req.Method)]
+			info, hasInfo := rpc.MethodInfoFromContext(ctx)
+			rs, hasSchema := MethodSchema{}, false
+			switch {
+			case serviceSchema == nil && hasInfo:
+				ms, err := compiled.get(info)
+				if err != nil {
+					return rpc.ErrorResponse(req.Id, rpc.WrapError(err, "", rpc.ErrCodeInternalError))
+				}
+				rs, hasSchema = ms, true
+			case serviceSchema != nil:
+				// requested name may differ from registered one in case
+				if hasInfo {
+					rs, hasSchema = serviceSchema[info.Name]
+				}
+				if !hasSchema {
+					rs, hasSchema = serviceSchema[req.Method]
+				}
+				if !hasSchema {
+					rs, hasSchema = serviceSchema[strings.ToLower(req.Method)]
+				}
+			}
 			if hasSchema && rs.Request != nil {
-				if errResp := formatError(ctx, req.Id, *rs.Request, req.Params); errResp != nil {
+				params := req.Params
+				if len(params) == 0 {
+					// missing params are validated as null
+					params = json.RawMessage("null")
+				}
+				if errResp := formatError(ctx, req.Id, *rs.Request, params); errResp != nil {
 					return errResp
 				}
 			}
 			resp := handler(ctx, req)
-			if hasSchema && rs.Response != nil {
+			// error responses have no result to validate
+			if hasSchema && rs.Response != nil && resp != nil && resp.Error == nil {
 				if errResp := formatError(ctx, req.Id, *rs.Response, resp.Result); errResp != nil {
 					return errResp
 				}
@@ -68,17 +106,49 @@ func Validation(serviceSchema ServiceSchema) (rpc.Middleware, error) {
 func formatError(ctx context.Context, requestId any, schema jsonschema.Schema, data json.RawMessage) *rpc.RpcResponse {
 	errs, err := schema.ValidateBytes(ctx, data)
 	if err != nil {
-		return rpc.ErrorResponse(requestId, err)
+		return rpc.ErrorResponse(requestId, rpc.WrapError(err, "", rpc.ErrCodeInvalidParams))
 	}
-	if errs != nil && len(errs) > 0 {
-		messages := []string{}
-		for _, msg := range errs {
-			messages = append(messages, fmt.Sprintf("%s: %s", msg.PropertyPath, msg.Message))
+	if len(errs) > 0 {
+		details := make([]ValidationError, 0, len(errs))
+		for _, e := range errs {
+			details = append(details, ValidationError{Path: e.PropertyPath, Message: e.Message})
 		}
-		return rpc.ErrorResponse(requestId, rpc.Error{
-			Code:    rpc.ErrCodeInvalidParams,
-			Message: strings.Join(messages, "\n"),
-		})
+		return rpc.ErrorResponse(requestId, rpc.ErrorFromCode(rpc.ErrCodeInvalidParams).WithData(details))
 	}
 	return nil
 }
+
+// schemaCache keeps schemas of methods compiled from rpc.MethodInfo.
+type schemaCache struct {
+	schemas sync.Map // method name -> cachedSchema
+}
+
+type cachedSchema struct {
+	params, result json.RawMessage
+	schema         MethodSchema
+}
+
+func (c *schemaCache) get(info rpc.MethodInfo) (MethodSchema, error) {
+	if v, ok := c.schemas.Load(info.Name); ok {
+		cs := v.(cachedSchema)
+		// middleware may be used by several servers having methods with the same name
+		if bytes.Equal(cs.params, info.ParamsSchema) && bytes.Equal(cs.result, info.ResultSchema) {
+			return cs.schema, nil
+		}
+	}
+	cs := cachedSchema{params: info.ParamsSchema, result: info.ResultSchema}
+	if info.ParamsSchema != nil {
+		cs.schema.Request = new(jsonschema.Schema)
+		if err := json.Unmarshal(info.ParamsSchema, cs.schema.Request); err != nil {
+			return MethodSchema{}, err
+		}
+	}
+	if info.ResultSchema != nil {
+		cs.schema.Response = new(jsonschema.Schema)
+		if err := json.Unmarshal(info.ResultSchema, cs.schema.Response); err != nil {
+			return MethodSchema{}, err
+		}
+	}
+	c.schemas.Store(info.Name, cs)
+	return cs.schema, nil
+}
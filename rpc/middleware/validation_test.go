@@ -0,0 +1,124 @@
+//Package middleware provides middlewares for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.neonxp.dev/jsonrpc2/rpc"
+)
+
+// validate resolves request and returns code of error response (0 for result).
+func validate(t *testing.T, s *rpc.RpcServer, method, params string) int {
+	t.Helper()
+	out := &bytes.Buffer{}
+	in := `{"jsonrpc":"2.0","method":"` + method + `","params":` + params + `,"id":1}`
+	s.Resolve(context.Background(), strings.NewReader(in), out, false)
+	res := struct {
+		Error *rpc.Error `json:"error"`
+	}{}
+	if err := json.Unmarshal(out.Bytes(), &res); err != nil {
+		t.Fatalf("invalid response %q: %v", out, err)
+	}
+	if res.Error != nil {
+		return res.Error.Code
+	}
+	return 0
+}
+
+func TestValidationLooksUpRegisteredName(t *testing.T) {
+	validation, err := Validation(MustSchema(`{
+		"getUser": {"request": {"type": "object", "properties": {"id": {"type": "integer"}}, "required": ["id"]}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := rpc.New(rpc.WithMiddleware(validation))
+	s.Register("getUser", rpc.HandlerFunc(func(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+		return params, nil
+	}))
+	for _, method := range []string{"getUser", "getuser", "GETUSER"} {
+		if code := validate(t, s, method, `{"id":"x"}`); code != rpc.ErrCodeInvalidParams {
+			t.Fatalf("%s with invalid params: code %d, want %d", method, code, rpc.ErrCodeInvalidParams)
+		}
+		if code := validate(t, s, method, `{"id":1}`); code != 0 {
+			t.Fatalf("%s with valid params: code %d, want 0", method, code)
+		}
+	}
+}
+
+type adultParams struct {
+	Age int `json:"age" min:"18"`
+}
+
+func TestValidationGeneratedSchemas(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema ServiceSchema
+		want   int
+	}{
+		{"nil schema validates by Go types", nil, rpc.ErrCodeInvalidParams},
+		{"methods missing in schema are not validated", MustSchema(`{"other": {}}`), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validation, err := Validation(tt.schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := rpc.New(rpc.WithMiddleware(validation))
+			s.Register("register", rpc.H(func(ctx context.Context, p *adultParams) (int, error) { return p.Age, nil }))
+			if code := validate(t, s, "register", `{"age":1}`); code != tt.want {
+				t.Fatalf("code %d, want %d", code, tt.want)
+			}
+			if code := validate(t, s, "register", `{"age":20}`); code != 0 {
+				t.Fatalf("valid params: code %d, want 0", code)
+			}
+		})
+	}
+}
+
+func TestValidationPositionalParams(t *testing.T) {
+	validation, err := Validation(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := rpc.New(rpc.WithMiddleware(validation))
+	s.Register("add", rpc.H2(func(ctx context.Context, a, b int) (int, error) { return a + b, nil }, "a", "b"))
+	tests := []struct {
+		params string
+		want   int
+	}{
+		{`[3, 4]`, 0},
+		{`[3]`, 0},
+		{`{"a": 3}`, 0},
+		{`["x"]`, rpc.ErrCodeInvalidParams},
+		{`{"a": "x"}`, rpc.ErrCodeInvalidParams},
+	}
+	for _, tt := range tests {
+		if code := validate(t, s, "add", tt.params); code != tt.want {
+			t.Fatalf("add %s: code %d, want %d", tt.params, code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// DiscoverMethod is built-in method returning OpenRPC document of server.
+// It may be overridden by registering method with the same name.
+const DiscoverMethod = "rpc.discover"
+
+const openRPCVersion = "1.2.6"
+
+// OpenRPCDocument is OpenRPC (https://spec.open-rpc.org) description of server.
+type OpenRPCDocument struct {
+	OpenRPC string          `json:"openrpc"`
+	Info    OpenRPCInfo     `json:"info"`
+	Methods []OpenRPCMethod `json:"methods"`
+}
+
+type OpenRPCInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenRPCMethod struct {
+	Name           string                     `json:"name"`
+	Description    string                     `json:"description,omitempty"`
+	Params         []OpenRPCContentDescriptor `json:"params"`
+	Result         *OpenRPCContentDescriptor  `json:"result,omitempty"`
+	ParamStructure string                     `json:"paramStructure,omitempty"`
+}
+
+type OpenRPCContentDescriptor struct {
+	Name     string          `json:"name"`
+	Required bool            `json:"required,omitempty"`
+	Schema   json.RawMessage `json:"schema"`
+}
+
+var defaultOpenRPCInfo = OpenRPCInfo{
+	Title:   "JSON-RPC API",
+	Version: "1.0.0",
+}
+
+// OpenRPC returns OpenRPC document describing registered methods. Schemas of params and results
+// are taken from method options (see MethodSchema) or generated from Go types of handlers made by H and HS
+// (see TypeSchema).
+func (r *RpcServer) OpenRPC() OpenRPCDocument {
+	r.mu.RLock()
+	info := r.openRPCInfo
+	r.mu.RUnlock()
+	doc := OpenRPCDocument{
+		OpenRPC: openRPCVersion,
+		Info:    info,
+		Methods: []OpenRPCMethod{},
+	}
+	for _, m := range r.Methods() {
+		doc.Methods = append(doc.Methods, openRPCMethod(m))
+	}
+	return doc
+}
+
+func openRPCMethod(info MethodInfo) OpenRPCMethod {
+	m := OpenRPCMethod{
+		Name:        info.Name,
+		Description: info.Description,
+		Params:      []OpenRPCContentDescriptor{},
+	}
+	if params := info.ParamsSchema; params != nil {
+		if props, ok := objectProperties(params); ok {
+			m.ParamStructure = "by-name"
+			if info.ParamNames != nil {
+				m.ParamStructure = "either"
+			}
+			m.Params = props
+		} else {
+			m.Params = append(m.Params, OpenRPCContentDescriptor{Name: "params", Required: true, Schema: params})
+		}
+	}
+	result := info.ResultSchema
+	if result == nil {
+		result = json.RawMessage("{}")
+	}
+	m.Result = &OpenRPCContentDescriptor{Name: "result", Schema: result}
+	return m
+}
+
+// objectProperties returns properties of object schema as content descriptors in order of definition.
+func objectProperties(schema json.RawMessage) ([]OpenRPCContentDescriptor, bool) {
+	object := struct {
+		Type       string          `json:"type"`
+		Properties json.RawMessage `json:"properties"`
+		Required   []string        `json:"required"`
+	}{}
+	if err := json.Unmarshal(schema, &object); err != nil || object.Type != "object" || object.Properties == nil {
+		return nil, false
+	}
+	required := map[string]bool{}
+	for _, name := range object.Required {
+		required[name] = true
+	}
+	// properties are decoded token by token to keep their order
+	dec := json.NewDecoder(bytes.NewReader(object.Properties))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, false
+	}
+	props := []OpenRPCContentDescriptor{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, false
+		}
+		name, _ := tok.(string)
+		var propSchema json.RawMessage
+		if err := dec.Decode(&propSchema); err != nil {
+			return nil, false
+		}
+		props = append(props, OpenRPCContentDescriptor{
+			Name:     name,
+			Required: required[name],
+			Schema:   propSchema,
+		})
+	}
+	return props, true
+}
+
+func (r *RpcServer) discoverMethod() *method {
+	return &method{
+		info: MethodInfo{
+			Name:        DiscoverMethod,
+			Description: "Returns OpenRPC document describing this server",
+		},
+		handler: HS(func(ctx context.Context) (OpenRPCDocument, error) {
+			return r.OpenRPC(), nil
+		}),
+	}
+}
@@ -20,6 +20,8 @@
 package rpc
 
 import (
+	"time"
+
 	"go.neonxp.dev/jsonrpc2/transport"
 )
 
@@ -42,3 +44,96 @@ func WithLogger(l Logger) Option {
 		s.logger = l
 	}
 }
+
+// WithErrorMapper sets function that converts handler errors to rpc errors.
+// By default such errors are returned to client as "Internal error" without details.
+func WithErrorMapper(m ErrorMapper) Option {
+	return func(s *RpcServer) {
+		if m == nil {
+			m = defaultErrorMapper
+		}
+		s.errorMapper = m
+	}
+}
+
+// WithTimeout sets default timeout of request. Handler context gets deadline,
+// and if handler fails because of it, client receives ErrCodeTimeout error.
+// Zero means no timeout (default).
+func WithTimeout(d time.Duration) Option {
+	return func(s *RpcServer) {
+		s.timeout = d
+	}
+}
+
+// WithMethodTimeout sets timeout of given method overriding default timeout.
+func WithMethodTimeout(method string, d time.Duration) Option {
+	return func(s *RpcServer) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.methodTimeouts[method] = d
+	}
+}
+
+// WithMaxConcurrency limits number of requests executed by server at once. Each request of batch is counted,
+// and batch is rejected entirely if it doesn't fit, so batch longer than limit is always rejected (see WithMaxBatchSize).
+// Requests over limit are rejected with ErrCodeTooManyRequests error. Zero means no limit (default).
+func WithMaxConcurrency(n int) Option {
+	return func(s *RpcServer) {
+		s.maxConcurrency = n
+	}
+}
+
+// WithMaxConnConcurrency limits number of requests executed or queued at once for single connection,
+// e.g. TCP connection with parallel execution. Requests of batch are counted like by WithMaxConcurrency.
+// Requests over limit are rejected with ErrCodeTooManyRequests error. Zero means no limit (default).
+func WithMaxConnConcurrency(n int) Option {
+	return func(s *RpcServer) {
+		s.maxConnConcurrency = n
+	}
+}
+
+// WithMaxMessageSize limits size of incoming message in bytes. Transports stop reading message exceeding it,
+// client receives ErrCodeMessageTooLarge error (HTTP transport responds with status 413).
+// Stream of concatenated JSON values can't be recovered after such message, so connection is closed.
+// Zero means no limit (default).
+func WithMaxMessageSize(n int64) Option {
+	return func(s *RpcServer) {
+		s.maxMessageSize = n
+	}
+}
+
+// WithMaxBatchSize limits number of requests in batch. Larger batches are rejected with ErrCodeMessageTooLarge error.
+// Zero means no limit (default).
+func WithMaxBatchSize(n int) Option {
+	return func(s *RpcServer) {
+		s.maxBatchSize = n
+	}
+}
+
+// WithMaxDepth limits nesting depth of arrays and objects in request (request object is level 1).
+// Deeper requests are rejected with ErrCodeMessageTooLarge error. Zero means no limit (default).
+func WithMaxDepth(n int) Option {
+	return func(s *RpcServer) {
+		s.maxDepth = n
+	}
+}
+
+// WithCaseSensitiveMethods makes method names case-sensitive, as JSON-RPC specification implies.
+// By default "getUser" and "getuser" are the same method.
+// Must be passed to New before methods are registered.
+func WithCaseSensitiveMethods() Option {
+	return func(s *RpcServer) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.caseSensitive = true
+	}
+}
+
+// WithOpenRPCInfo sets info section of OpenRPC document returned by rpc.discover method.
+func WithOpenRPCInfo(info OpenRPCInfo) Option {
+	return func(s *RpcServer) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.openRPCInfo = info
+	}
+}
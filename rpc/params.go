@@ -0,0 +1,109 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// decodeParams unmarshals params to target. Missing or null params leave target unchanged.
+// If names are set, positional (array) params are assigned to them in order.
+func decodeParams(params json.RawMessage, target any, names []string) error {
+	if isAbsent(params) {
+		return nil
+	}
+	if names != nil && isBatch(params) {
+		obj, err := positionalToNamed(params, names)
+		if err != nil {
+			return err
+		}
+		params = obj
+	}
+	return json.Unmarshal(params, target)
+}
+
+// positionalToNamed converts array params to object with given names of elements.
+func positionalToNamed(params json.RawMessage, names []string) (json.RawMessage, error) {
+	values := []json.RawMessage{}
+	if err := json.Unmarshal(params, &values); err != nil {
+		return nil, err
+	}
+	if len(values) > len(names) {
+		return nil, fmt.Errorf("too many params: got %d, expected at most %d", len(values), len(names))
+	}
+	buf := bytes.NewBufferString("{")
+	for i, v := range values {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(names[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// isAbsent reports whether params are missing or null.
+func isAbsent(params json.RawMessage) bool {
+	params = bytes.TrimSpace(params)
+	return len(params) == 0 || bytes.Equal(params, []byte("null"))
+}
+
+// paramNames returns names of params in order of struct fields, or nil if params are not struct.
+func paramNames(t reflect.Type) []string {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct || t == timeType {
+		return nil
+	}
+	props, _ := structProperties(t, map[reflect.Type]bool{t: true}, false)
+	names := make([]string, 0, len(props))
+	for _, p := range props {
+		names = append(names, p.name)
+	}
+	return names
+}
+
+// paramsStruct returns struct type with optional fields of given types named by names (param1, param2, etc by default).
+// It describes params of handlers made by H2 and H3.
+func paramsStruct(names []string, types ...reflect.Type) reflect.Type {
+	fields := make([]reflect.StructField, len(types))
+	for i, t := range types {
+		name := fmt.Sprintf("param%d", i+1)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("P%d", i),
+			Type: t,
+			Tag:  reflect.StructTag(fmt.Sprintf(`json:%q`, name+",omitempty")),
+		}
+	}
+	return reflect.StructOf(fields)
+}
@@ -0,0 +1,108 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type pairParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func TestPositionalParams(t *testing.T) {
+	s := New()
+	s.Register("pair", H(func(ctx context.Context, p *pairParams) ([]int, error) {
+		return []int{p.A, p.B}, nil
+	}))
+	s.Register("named", H2(func(ctx context.Context, a int, b *string) ([]any, error) {
+		return []any{a, b}, nil
+	}, "a", "b"))
+	s.Register("default", H3(func(ctx context.Context, a, b int, c string) ([]any, error) {
+		return []any{a, b, c}, nil
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		params   string
+		want     string
+		wantCode int
+	}{
+		{"struct by position", "pair", `[3, 4]`, `[3,4]`, 0},
+		{"struct by name", "pair", `{"b": 4, "a": 3}`, `[3,4]`, 0},
+		{"struct missing positions", "pair", `[3]`, `[3,0]`, 0},
+		{"struct too many", "pair", `[3, 4, 5]`, ``, ErrCodeInvalidParams},
+		{"H2 by position", "named", `[1, "x"]`, `[1,"x"]`, 0},
+		{"H2 by name", "named", `{"a": 1, "b": "x"}`, `[1,"x"]`, 0},
+		{"H2 missing params are zero", "named", `{"a": 1}`, `[1,null]`, 0},
+		{"H2 null params", "named", `null`, `[0,null]`, 0},
+		{"H2 invalid type", "named", `["x"]`, ``, ErrCodeInvalidParams},
+		{"H3 default names", "default", `{"param1": 1, "param3": "z"}`, `[1,0,"z"]`, 0},
+		{"H3 by position", "default", `[1, 2, "z"]`, `[1,2,"z"]`, 0},
+		{"H3 too many", "default", `[1, 2, "z", 4]`, ``, ErrCodeInvalidParams},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			in := `{"jsonrpc":"2.0","method":"` + tt.method + `","params":` + tt.params + `,"id":1}`
+			s.Resolve(context.Background(), strings.NewReader(in), out, false)
+			resp := testResponse{}
+			if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+				t.Fatalf("response %q: %v", out, err)
+			}
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if string(resp.Result) != tt.want || code != tt.wantCode {
+				t.Fatalf("got %s:%d, want %s:%d", resp.Result, code, tt.want, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestPositionalParamsSchema(t *testing.T) {
+	s := New()
+	s.Register("add", H2(func(ctx context.Context, a, b int) (int, error) { return a + b, nil }, "a", "b"))
+	info := s.Methods()[0]
+	if strings.Join(info.ParamNames, ",") != "a,b" {
+		t.Fatalf("param names %v, want [a b]", info.ParamNames)
+	}
+	schema := struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+		Required   []string                   `json:"required"`
+	}{}
+	if err := json.Unmarshal(info.ParamsSchema, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if len(schema.Properties) != 2 || schema.Properties["a"] == nil || schema.Properties["b"] == nil {
+		t.Fatalf("properties of schema %s, want a and b", info.ParamsSchema)
+	}
+	// missing params are zero, so they are not required
+	if len(schema.Required) != 0 {
+		t.Fatalf("required params %v, want none", schema.Required)
+	}
+}
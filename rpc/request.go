@@ -0,0 +1,60 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// parseRequest decodes single request object and validates it against JSON-RPC 2.0 specification.
+// If request is invalid, it returns Invalid Request error response.
+func parseRequest(msg json.RawMessage) (*RpcRequest, *RpcResponse) {
+	req := new(RpcRequest)
+	if err := json.Unmarshal(msg, req); err != nil {
+		return nil, ErrorResponse(nil, ErrorFromCode(ErrCodeInvalidRequest))
+	}
+	if !isValidId(req.Id) {
+		return nil, ErrorResponse(nil, ErrorFromCode(ErrCodeInvalidRequest))
+	}
+	if req.Jsonrpc != version || req.Method == "" || !isValidParams(req.Params) {
+		return nil, ErrorResponse(req.Id, ErrorFromCode(ErrCodeInvalidRequest))
+	}
+	return req, nil
+}
+
+// isValidId checks that id is a string, number or null.
+func isValidId(id any) bool {
+	switch id.(type) {
+	case nil, string, float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isValidParams checks that params are omitted or structured value (object or array).
+func isValidParams(params json.RawMessage) bool {
+	params = bytes.TrimSpace(params)
+	if len(params) == 0 || bytes.Equal(params, []byte("null")) {
+		return true
+	}
+	return params[0] == '{' || params[0] == '['
+}
@@ -0,0 +1,98 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		wantCode int    // 0 if request is valid
+		wantId   string // id of error response
+	}{
+		{"call", `{"jsonrpc":"2.0","method":"sum","params":[1,2],"id":1}`, 0, ""},
+		{"named params and string id", `{"jsonrpc":"2.0","method":"sum","params":{"a":1},"id":"x"}`, 0, ""},
+		{"notification without params", `{"jsonrpc":"2.0","method":"ping"}`, 0, ""},
+		{"null params and id", `{"jsonrpc":"2.0","method":"ping","params":null,"id":null}`, 0, ""},
+		{"not object", `[1]`, ErrCodeInvalidRequest, "null"},
+		{"string", `"foo"`, ErrCodeInvalidRequest, "null"},
+		{"object id", `{"jsonrpc":"2.0","method":"ping","id":{}}`, ErrCodeInvalidRequest, "null"},
+		{"bool id", `{"jsonrpc":"2.0","method":"ping","id":true}`, ErrCodeInvalidRequest, "null"},
+		{"missing version", `{"method":"ping","id":1}`, ErrCodeInvalidRequest, "1"},
+		{"wrong version", `{"jsonrpc":"1.0","method":"ping","id":1}`, ErrCodeInvalidRequest, "1"},
+		{"missing method", `{"jsonrpc":"2.0","id":"a"}`, ErrCodeInvalidRequest, `"a"`},
+		{"method is not string", `{"jsonrpc":"2.0","method":1,"id":1}`, ErrCodeInvalidRequest, "null"},
+		{"scalar params", `{"jsonrpc":"2.0","method":"ping","params":1,"id":2}`, ErrCodeInvalidRequest, "2"},
+		{"string params", `{"jsonrpc":"2.0","method":"ping","params":"a","id":2}`, ErrCodeInvalidRequest, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, resp := parseRequest(json.RawMessage(tt.msg))
+			if tt.wantCode == 0 {
+				if resp != nil || req == nil {
+					t.Fatalf("parseRequest() = %v, %v, want valid request", req, resp)
+				}
+				return
+			}
+			if resp == nil {
+				t.Fatalf("parseRequest() = %v, want error response", req)
+			}
+			out, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := testResponse{}
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Error == nil || got.Error.Code != tt.wantCode || string(got.Id) != tt.wantId {
+				t.Fatalf("response = %s, want code %d and id %s", out, tt.wantCode, tt.wantId)
+			}
+		})
+	}
+}
+
+func TestResultResponse(t *testing.T) {
+	tests := []struct {
+		name   string
+		result json.RawMessage
+		want   string
+	}{
+		{"nil result", nil, `{"jsonrpc":"2.0","result":null,"id":1}`},
+		{"empty result", json.RawMessage{}, `{"jsonrpc":"2.0","result":null,"id":1}`},
+		{"null result", json.RawMessage("null"), `{"jsonrpc":"2.0","result":null,"id":1}`},
+		{"result", json.RawMessage(`{"a":1}`), `{"jsonrpc":"2.0","result":{"a":1},"id":1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := json.Marshal(ResultResponse(1, tt.result))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Fatalf("got %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMethodExists is returned on registration of method which name collides with registered one.
+var ErrMethodExists = errors.New("jsonrpc2: method already registered")
+
+// Router registers methods. It is implemented by RpcServer and Group.
+type Router interface {
+	// Register registers method handler.
+	Register(method string, handler Handler, opts ...MethodOption) error
+	// Group returns router registering methods under "prefix." namespace.
+	Group(prefix string, middlewares ...Middleware) *Group
+	// Mount serves methods of another server under "prefix." namespace.
+	Mount(prefix string, server *RpcServer)
+	// RegisterService registers exported methods of svc under "prefix." namespace.
+	RegisterService(prefix string, svc any, opts ...ServiceOption) error
+}
+
+var (
+	_ Router = (*RpcServer)(nil)
+	_ Router = (*Group)(nil)
+)
+
+// Group is a namespace of methods with own middlewares.
+// Group middlewares run only for methods of group and its subgroups, after server middlewares.
+type Group struct {
+	server      *RpcServer
+	parent      *Group
+	prefix      string // full prefix including prefixes of parent groups
+	middlewares []Middleware
+}
+
+// Group returns group of methods with names "prefix.method".
+func (r *RpcServer) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		server:      r,
+		prefix:      prefix,
+		middlewares: middlewares,
+	}
+}
+
+// Mount serves all methods of server as "prefix.method". Mounted server resolves them with
+// its own middlewares, timeouts and error mapper. Methods registered on it later are served too.
+func (r *RpcServer) Mount(prefix string, server *RpcServer) {
+	r.mount(prefix, server, nil)
+}
+
+// Group returns nested group of methods with names "groupPrefix.prefix.method".
+func (g *Group) Group(prefix string, middlewares ...Middleware) *Group {
+	return &Group{
+		server:      g.server,
+		parent:      g,
+		prefix:      joinMethod(g.prefix, prefix),
+		middlewares: middlewares,
+	}
+}
+
+// Use adds middlewares to group.
+func (g *Group) Use(middlewares ...Middleware) {
+	g.server.mu.Lock()
+	defer g.server.mu.Unlock()
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+// Register registers method as "prefix.method".
+func (g *Group) Register(method string, handler Handler, opts ...MethodOption) error {
+	return g.server.register(joinMethod(g.prefix, method), handler, g, opts)
+}
+
+// Mount serves all methods of server as "groupPrefix.prefix.method" with middlewares of group.
+func (g *Group) Mount(prefix string, server *RpcServer) {
+	g.server.mount(joinMethod(g.prefix, prefix), server, g)
+}
+
+// wrap applies middlewares of group and its parents to handler. Middlewares of parents are outer.
+func (g *Group) wrap(h RpcHandler) RpcHandler {
+	for ; g != nil; g = g.parent {
+		g.server.mu.RLock()
+		middlewares := g.middlewares
+		g.server.mu.RUnlock()
+		for _, m := range middlewares {
+			h = m(h)
+		}
+	}
+	return h
+}
+
+// mount is server mounted as namespace.
+type mount struct {
+	prefix string
+	server *RpcServer
+	group  *Group
+}
+
+func (r *RpcServer) register(name string, handler Handler, group *Group, opts []MethodOption) error {
+	m := newMethod(name, handler, group, opts)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.checkCollision(name); err != nil {
+		return err
+	}
+	r.addMethod(m)
+	return nil
+}
+
+func newMethod(name string, handler Handler, group *Group, opts []MethodOption) *method {
+	m := &method{
+		info:    MethodInfo{Name: name},
+		handler: handler,
+		group:   group,
+	}
+	if th, ok := handler.(TypedHandler); ok {
+		m.info.ParamsType, m.info.ResultType = th.Types()
+		// schemas set by options take precedence
+		if m.info.ParamsType != nil {
+			m.info.ParamsSchema = TypeSchema(m.info.ParamsType)
+			m.info.ParamNames = paramNames(m.info.ParamsType)
+		}
+		if m.info.ResultType != nil {
+			m.info.ResultSchema = TypeSchema(m.info.ResultType)
+		}
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// checkCollision returns error if method with the same key is registered. r.mu must be held.
+func (r *RpcServer) checkCollision(name string) error {
+	if existing, ok := r.methods[r.methodKey(name)]; ok {
+		return fmt.Errorf("%w: %s collides with %s", ErrMethodExists, name, existing.info.Name)
+	}
+	return nil
+}
+
+// addMethod adds checked method. r.mu must be held.
+func (r *RpcServer) addMethod(m *method) {
+	r.logger.Logf("Register method %s", m.info.Name)
+	r.methods[r.methodKey(m.info.Name)] = m
+}
+
+func (r *RpcServer) mount(prefix string, server *RpcServer, group *Group) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.logger.Logf("Mount server at %s", prefix)
+	r.mounts = append(r.mounts, mount{
+		prefix: prefix,
+		server: server,
+		group:  group,
+	})
+}
+
+// route returns handler of method with middlewares of method and its group, and method itself
+// (nil for methods of mounted servers).
+// Methods registered directly take precedence over mounted servers, longest mount prefix wins.
+func (r *RpcServer) route(name string) (RpcHandler, *method, bool) {
+	r.mu.RLock()
+	key := r.methodKey(name)
+	m, ok := r.methods[key]
+	isDiscover := key == r.methodKey(DiscoverMethod)
+	r.mu.RUnlock()
+	if ok {
+		return m.wrap(r.methodHandler(m)), m, true
+	}
+	mnt := r.findMount(name)
+	if mnt == nil {
+		if isDiscover {
+			m := r.discoverMethod()
+			return r.methodHandler(m), m, true
+		}
+		return nil, nil, false
+	}
+	sub, rest := mnt.server, name[len(mnt.prefix)+1:]
+	h := func(ctx context.Context, req *RpcRequest) *RpcResponse {
+		// mounted server sees method names without prefix
+		subReq := *req
+		subReq.Method = rest
+		return sub.handle(ctx, &subReq)
+	}
+	return mnt.group.wrap(h), nil, true
+}
+
+// findMount returns mount with longest prefix of method name or nil.
+func (r *RpcServer) findMount(name string) *mount {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key := r.methodKey(name)
+	var mnt *mount
+	for i := range r.mounts {
+		prefix := r.methodKey(r.mounts[i].prefix) + "."
+		if strings.HasPrefix(key, prefix) && (mnt == nil || len(r.mounts[i].prefix) > len(mnt.prefix)) {
+			mnt = &r.mounts[i]
+		}
+	}
+	return mnt
+}
+
+// mountedMethodInfo returns info of method of mounted server with name including mount prefix.
+func (r *RpcServer) mountedMethodInfo(name string) (MethodInfo, bool) {
+	mnt := r.findMount(name)
+	if mnt == nil {
+		return MethodInfo{}, false
+	}
+	rest := name[len(mnt.prefix)+1:]
+	info, ok := MethodInfo{}, false
+	if _, m, _ := mnt.server.route(rest); m != nil {
+		info, ok = m.info, true
+	} else {
+		info, ok = mnt.server.mountedMethodInfo(rest)
+	}
+	if !ok {
+		return MethodInfo{}, false
+	}
+	info.Name = joinMethod(mnt.prefix, info.Name)
+	return info, true
+}
+
+// methodHandler calls registered handler and converts its result to response.
+func (r *RpcServer) methodHandler(m *method) RpcHandler {
+	return func(ctx context.Context, req *RpcRequest) *RpcResponse {
+		resp, err := m.handler.Handle(ctx, req.Params)
+		if err != nil {
+			r.logger.Logf("User error %v", err)
+			return ErrorResponse(req.Id, r.contextError(ctx, err))
+		}
+		return ResultResponse(req.Id, resp)
+	}
+}
+
+// methodKey returns key of method in registry. Must be called with r.mu held.
+func (r *RpcServer) methodKey(name string) string {
+	if r.caseSensitive {
+		return name
+	}
+	return strings.ToLower(name)
+}
+
+// joinMethod joins namespace and method name with dot.
+func joinMethod(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "." + name
+}
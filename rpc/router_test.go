@@ -0,0 +1,197 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// call resolves single request and returns its result or error code.
+func call(t *testing.T, s *RpcServer, method string) (string, int) {
+	t.Helper()
+	out := &bytes.Buffer{}
+	s.Resolve(context.Background(), strings.NewReader(`{"jsonrpc":"2.0","method":"`+method+`","id":1}`), out, false)
+	resp := testResponse{}
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("response %q: %v", out, err)
+	}
+	if resp.Error != nil {
+		return "", resp.Error.Code
+	}
+	result := ""
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("result %s: %v", resp.Result, err)
+	}
+	return result, 0
+}
+
+// methodName returns name of method as seen by handler.
+var methodName = H(func(ctx context.Context, _ *struct{}) (string, error) {
+	info, _ := MethodInfoFromContext(ctx)
+	return info.Name, nil
+})
+
+func TestRouter(t *testing.T) {
+	trace := []string{}
+	tag := func(name string) Middleware {
+		return func(next RpcHandler) RpcHandler {
+			return func(ctx context.Context, req *RpcRequest) *RpcResponse {
+				entry := name
+				if info, ok := MethodInfoFromContext(ctx); ok {
+					entry += ":" + info.Name
+				}
+				trace = append(trace, entry)
+				return next(ctx, req)
+			}
+		}
+	}
+	// tag without method name
+	mark := func(name string) Middleware {
+		return func(next RpcHandler) RpcHandler {
+			return func(ctx context.Context, req *RpcRequest) *RpcResponse {
+				trace = append(trace, name)
+				return next(ctx, req)
+			}
+		}
+	}
+
+	s := New(WithMiddleware(tag("server")))
+	s.Register("name", methodName)
+	api := s.Group("api", mark("api"))
+	api.Register("name", methodName, MethodMiddleware(mark("method")))
+	v1 := api.Group("v1", mark("v1"))
+	v1.Register("name", methodName)
+	api.Use(mark("api-later"))
+
+	sub := New(WithMiddleware(tag("sub")))
+	sub.Register("name", methodName)
+	sub.Register("own", methodName)
+	deep := New()
+	deep.Register("name", methodName)
+	s.Mount("sub", sub)
+	s.Mount("sub.deep", deep)
+	api.Mount("sub", sub)
+	s.Register("sub.own", methodName)
+	sub.Register("later", methodName)
+
+	tests := []struct {
+		name      string
+		method    string
+		want      string
+		wantCode  int
+		wantTrace []string
+	}{
+		{
+			name:      "server method",
+			method:    "name",
+			want:      "name",
+			wantTrace: []string{"server:name"},
+		},
+		{
+			name:      "group method",
+			method:    "api.name",
+			want:      "api.name",
+			wantTrace: []string{"server:api.name", "api-later", "api", "method"},
+		},
+		{
+			name:      "nested group method",
+			method:    "API.V1.Name",
+			want:      "api.v1.name",
+			wantTrace: []string{"server:api.v1.name", "api-later", "api", "v1"},
+		},
+		{
+			name:      "mounted method",
+			method:    "sub.name",
+			want:      "name",
+			wantTrace: []string{"server:sub.name", "sub:name"},
+		},
+		{
+			name:      "method registered after mount",
+			method:    "sub.later",
+			want:      "later",
+			wantTrace: []string{"server:sub.later", "sub:later"},
+		},
+		{
+			name:      "registered method takes precedence over mount",
+			method:    "sub.own",
+			want:      "sub.own",
+			wantTrace: []string{"server:sub.own"},
+		},
+		{
+			name:      "longest mount prefix wins",
+			method:    "sub.deep.name",
+			want:      "name",
+			wantTrace: []string{"server:sub.deep.name"},
+		},
+		{
+			name:      "mounted in group",
+			method:    "api.sub.name",
+			want:      "name",
+			wantTrace: []string{"server:api.sub.name", "api-later", "api", "sub:name"},
+		},
+		{
+			name:      "unknown method of group",
+			method:    "api.nope",
+			wantCode:  ErrCodeMethodNotFound,
+			wantTrace: []string{"server"},
+		},
+		{
+			name:      "unknown method of mounted server",
+			method:    "sub.nope",
+			wantCode:  ErrCodeMethodNotFound,
+			wantTrace: []string{"server", "sub"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trace = []string{}
+			got, code := call(t, s, tt.method)
+			if got != tt.want || code != tt.wantCode {
+				t.Fatalf("got %q:%d, want %q:%d", got, code, tt.want, tt.wantCode)
+			}
+			if !reflect.DeepEqual(trace, tt.wantTrace) {
+				t.Fatalf("middlewares %v, want %v", trace, tt.wantTrace)
+			}
+		})
+	}
+}
+
+func TestGroupCollisions(t *testing.T) {
+	s := New()
+	api := s.Group("api")
+	if err := api.Register("name", methodName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Register("API.name", methodName); !errors.Is(err, ErrMethodExists) {
+		t.Fatalf("Register() error = %v, want ErrMethodExists", err)
+	}
+	if err := api.Group("v1").Register("name", methodName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Group("api.v1").Register("NAME", methodName); !errors.Is(err, ErrMethodExists) {
+		t.Fatalf("Register() error = %v, want ErrMethodExists", err)
+	}
+}
@@ -0,0 +1,315 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	rawMessageType    = reflect.TypeOf(json.RawMessage{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// TypeSchema returns JSON Schema describing JSON encoding of values of type t.
+//
+// Struct fields are named by json tags and are required unless they have omitempty option or are pointers
+// or interfaces.
+// Embedded structs are flattened like encoding/json does. Pointers, slices and maps may be null.
+// Constraints of fields are set by tags:
+//
+//	Name  string   `json:"name" min:"1" max:"64" pattern:"^[a-z]+$"` // minLength, maxLength and pattern
+//	Age   int      `json:"age" min:"0" max:"150"`                   // minimum and maximum
+//	Tags  []string `json:"tags" max:"10"`                           // maxItems
+//	Role  string   `json:"role" enum:"admin,user"`
+//	Email string   `json:"email" format:"email" description:"Contact email"`
+//	Note  *string  `json:"note" required:"true"`                    // overrides default
+func TypeSchema(t reflect.Type) json.RawMessage {
+	b, err := json.Marshal(typeSchema(t, map[reflect.Type]bool{}))
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return b
+}
+
+// typeSchema builds schema of type. Recursive types are described as any value from second level.
+func typeSchema(t reflect.Type, visiting map[reflect.Type]bool) map[string]any {
+	if t.Kind() == reflect.Ptr {
+		return nullable(typeSchema(t.Elem(), visiting))
+	}
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t == rawMessageType || t.Kind() == reflect.Interface:
+		return map[string]any{}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// custom encoding is unknown
+		return map[string]any{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return map[string]any{"type": "string"}
+	}
+	if visiting[t] {
+		return map[string]any{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is encoded as base64 string
+			return nullable(map[string]any{"type": "string", "contentEncoding": "base64"})
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		return nullable(map[string]any{"type": "array", "items": typeSchema(t.Elem(), visiting)})
+	case reflect.Array:
+		visiting[t] = true
+		defer delete(visiting, t)
+		return map[string]any{
+			"type":     "array",
+			"items":    typeSchema(t.Elem(), visiting),
+			"minItems": t.Len(),
+			"maxItems": t.Len(),
+		}
+	case reflect.Map:
+		visiting[t] = true
+		defer delete(visiting, t)
+		return nullable(map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem(), visiting)})
+	case reflect.Struct:
+		visiting[t] = true
+		defer delete(visiting, t)
+		properties, required := structProperties(t, visiting, true)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	}
+	// functions, channels, etc.
+	return map[string]any{}
+}
+
+// structProperties returns properties of struct and names of required ones.
+// Fields of embedded structs are flattened, fields of outer struct take precedence.
+func structProperties(t reflect.Type, visiting map[reflect.Type]bool, canRequire bool) (schemaProperties, []string) {
+	type field struct {
+		name     string
+		field    reflect.StructField
+		embedded reflect.Type
+	}
+	fields := []field{}
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			// unexported embedded structs still promote exported fields
+			fields = append(fields, field{field: f, embedded: ft})
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, field{name: name, field: f})
+		names[name] = true
+	}
+	properties := schemaProperties{}
+	required := []string{}
+	for _, f := range fields {
+		if f.embedded != nil {
+			if visiting[f.embedded] {
+				continue
+			}
+			visiting[f.embedded] = true
+			// fields of embedded pointer are absent when it is nil
+			props, req := structProperties(f.embedded, visiting, canRequire && f.field.Type.Kind() != reflect.Ptr)
+			delete(visiting, f.embedded)
+			reqSet := map[string]bool{}
+			for _, name := range req {
+				reqSet[name] = true
+			}
+			for _, p := range props {
+				if names[p.name] {
+					continue
+				}
+				names[p.name] = true
+				properties = append(properties, p)
+				if reqSet[p.name] {
+					required = append(required, p.name)
+				}
+			}
+			continue
+		}
+		schema := typeSchema(f.field.Type, visiting)
+		applyTags(schema, f.field)
+		properties = append(properties, schemaProperty{name: f.name, schema: schema})
+		if canRequire && isRequired(f.field) {
+			required = append(required, f.name)
+		}
+	}
+	return properties, required
+}
+
+// isRequired reports whether field must be present in JSON.
+func isRequired(f reflect.StructField) bool {
+	switch f.Tag.Get("required") {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	_, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+	for _, opt := range strings.Split(opts, ",") {
+		if opt == "omitempty" {
+			return false
+		}
+	}
+	// nil pointers and interfaces are the same as missing fields
+	return f.Type.Kind() != reflect.Ptr && f.Type.Kind() != reflect.Interface
+}
+
+// applyTags adds constraints from field tags to schema.
+func applyTags(schema map[string]any, f reflect.StructField) {
+	ft := f.Type
+	for ft.Kind() == reflect.Ptr {
+		ft = ft.Elem()
+	}
+	var minKey, maxKey string
+	switch ft.Kind() {
+	case reflect.String:
+		minKey, maxKey = "minLength", "maxLength"
+	case reflect.Slice, reflect.Array:
+		minKey, maxKey = "minItems", "maxItems"
+	case reflect.Map:
+		minKey, maxKey = "minProperties", "maxProperties"
+	default:
+		minKey, maxKey = "minimum", "maximum"
+	}
+	if v, ok := f.Tag.Lookup("min"); ok {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			schema[minKey] = n
+		}
+	}
+	if v, ok := f.Tag.Lookup("max"); ok {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			schema[maxKey] = n
+		}
+	}
+	if v, ok := f.Tag.Lookup("pattern"); ok {
+		schema["pattern"] = v
+	}
+	if v, ok := f.Tag.Lookup("format"); ok {
+		schema["format"] = v
+	}
+	if v, ok := f.Tag.Lookup("description"); ok {
+		schema["description"] = v
+	}
+	if v, ok := f.Tag.Lookup("enum"); ok {
+		values := []any{}
+		for _, s := range strings.Split(v, ",") {
+			values = append(values, enumValue(ft, s))
+		}
+		if f.Type.Kind() == reflect.Ptr {
+			values = append(values, nil)
+		}
+		schema["enum"] = values
+	}
+}
+
+// enumValue converts enum tag value to JSON value of field type.
+func enumValue(t reflect.Type, s string) any {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	}
+	return s
+}
+
+// nullable allows null in addition to type of schema.
+func nullable(schema map[string]any) map[string]any {
+	if t, ok := schema["type"].(string); ok {
+		schema["type"] = []string{t, "null"}
+	}
+	return schema
+}
+
+// schemaProperties are properties of object schema encoded in order of struct fields.
+type schemaProperties []schemaProperty
+
+type schemaProperty struct {
+	name   string
+	schema map[string]any
+}
+
+func (props schemaProperties) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+	for i, p := range props {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(p.name)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := json.Marshal(p.schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
@@ -22,9 +22,13 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
+	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"golang.org/x/sync/errgroup"
 
@@ -34,20 +38,41 @@ import (
 const version = "2.0"
 
 type RpcServer struct {
-	logger      Logger
-	handlers    map[string]HandlerFunc
-	middlewares []Middleware
-	transports  []transport.Transport
-	mu          sync.RWMutex
+	inflight           int64 // first field to be 64-bit aligned for atomic operations
+	logger             Logger
+	methods            map[string]*method
+	mounts             []mount
+	middlewares        []Middleware
+	errorMapper        ErrorMapper
+	caseSensitive      bool
+	openRPCInfo        OpenRPCInfo
+	timeout            time.Duration
+	methodTimeouts     map[string]time.Duration
+	maxConcurrency     int // limit of in-flight messages of server
+	maxConnConcurrency int // limit of in-flight messages of connection
+	maxMessageSize     int64
+	maxBatchSize       int
+	maxDepth           int
+	transports         []transport.Transport
+	mu                 sync.RWMutex
+
+	stop           context.CancelFunc // stops Run
+	shuttingDown   bool
+	handlersCtx    context.Context // cancelled when graceful shutdown timed out
+	cancelHandlers context.CancelFunc
 }
 
 func New(opts ...Option) *RpcServer {
 	s := &RpcServer{
-		logger:     nopLogger{},
-		handlers:   map[string]HandlerFunc{},
-		transports: []transport.Transport{},
-		mu:         sync.RWMutex{},
+		logger:         nopLogger{},
+		methods:        map[string]*method{},
+		errorMapper:    defaultErrorMapper,
+		openRPCInfo:    defaultOpenRPCInfo,
+		methodTimeouts: map[string]time.Duration{},
+		transports:     []transport.Transport{},
+		mu:             sync.RWMutex{},
 	}
+	s.handlersCtx, s.cancelHandlers = context.WithCancel(context.Background())
 	for _, opt := range opts {
 		opt(s)
 	}
@@ -60,85 +85,443 @@ func (r *RpcServer) Use(opts ...Option) {
 	}
 }
 
-func (r *RpcServer) Register(method string, handler HandlerFunc) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.logger.Logf("Register method %s", method)
-	r.handlers[strings.ToLower(method)] = handler
+// Register registers method handler. Options configure only this method.
+// Returns ErrMethodExists if method with the same name (in terms of case sensitivity) is registered.
+func (r *RpcServer) Register(method string, handler Handler, opts ...MethodOption) error {
+	return r.register(method, handler, nil, opts)
 }
 
 func (r *RpcServer) Run(ctx context.Context) error {
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+	r.mu.Lock()
+	r.stop = stop
+	r.mu.Unlock()
 	eg, ctx := errgroup.WithContext(ctx)
 	for _, t := range r.transports {
 		eg.Go(func(t transport.Transport) func() error {
-			return func() error { return t.Run(ctx, r) }
+			return func() error {
+				if err := t.Run(ctx, r); err != nil {
+					return err
+				}
+				// Transport returns right after Shutdown call, keep ctx of in-flight requests until it finishes.
+				if r.isShuttingDown() {
+					<-ctx.Done()
+				}
+				return nil
+			}
 		}(t))
 	}
 	return eg.Wait()
 }
 
+// Shutdown gracefully stops server. Transports stop accepting new connections and requests,
+// in-flight requests are resolved and their responses are sent until ctx is done.
+// After that contexts of remaining handlers are cancelled. Run returns after Shutdown.
+func (r *RpcServer) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	r.shuttingDown = true
+	transports, stop := r.transports, r.stop
+	r.mu.Unlock()
+	if stop != nil {
+		defer stop()
+	}
+
+	errs := make(chan error, len(transports))
+	wg := sync.WaitGroup{}
+	for _, t := range transports {
+		sd, ok := t.(transport.Shutdowner)
+		if !ok {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sd.Shutdown(ctx); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Transports without Shutdowner and mounted http handlers still may resolve requests.
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&r.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			r.logger.Logf("Shutdown timed out, cancelling %d requests", atomic.LoadInt64(&r.inflight))
+			r.cancelHandlers()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return <-errs
+}
+
+const shutdownPollInterval = 10 * time.Millisecond
+
+func (r *RpcServer) isShuttingDown() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.shuttingDown
+}
+
+// ServeHTTP allows to mount server on any http router with default options.
+// Use transport.NewHTTPHandler for custom options.
+func (r *RpcServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	transport.NewHTTPHandler(r, transport.HTTPOptions{}).ServeHTTP(w, req)
+}
+
+// Resolve serves connection transferring stream of concatenated JSON values.
 func (r *RpcServer) Resolve(ctx context.Context, rd io.Reader, w io.Writer, parallel bool) {
+	var limited *limitedReader
+	if r.maxMessageSize > 0 {
+		limited = &limitedReader{rd: rd, limit: r.maxMessageSize}
+		rd = limited
+	}
 	dec := json.NewDecoder(rd)
 	enc := json.NewEncoder(w)
+	read := func() (json.RawMessage, error) {
+		if limited != nil {
+			limited.start = dec.InputOffset()
+		}
+		msg := json.RawMessage{}
+		if err := dec.Decode(&msg); err != nil {
+			if isParseError(err) {
+				return nil, errInvalidJSON
+			}
+			return nil, err
+		}
+		return msg, nil
+	}
+	write := func(msg any) error {
+		if err := enc.Encode(msg); err != nil {
+			return err
+		}
+		if w, canFlush := w.(Flusher); canFlush {
+			w.Flush()
+		}
+		return nil
+	}
+	r.serve(ctx, read, write, parallel, false)
+}
+
+// ResolveFrames serves connection transferring framed messages.
+// Unlike Resolve, frame with invalid JSON is answered with Parse error and connection keeps working.
+func (r *RpcServer) ResolveFrames(ctx context.Context, fr transport.FrameReader, fw transport.FrameWriter, parallel bool) {
+	read := func() (json.RawMessage, error) {
+		frame, err := fr.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if r.maxMessageSize > 0 && int64(len(frame)) > r.maxMessageSize {
+			return nil, transport.ErrMessageTooLarge
+		}
+		if !json.Valid(frame) {
+			return nil, errInvalidJSON
+		}
+		return frame, nil
+	}
+	write := func(msg any) error {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		return fw.WriteFrame(b)
+	}
+	r.serve(ctx, read, write, parallel, true)
+}
+
+var errInvalidJSON = errors.New("invalid json")
+
+// serve reads messages until error and resolves them.
+// Invalid JSON is answered with Parse error. Reading continues after it only for framed messages,
+// because stream of JSON values can't be recovered.
+func (r *RpcServer) serve(ctx context.Context, read func() (json.RawMessage, error), write func(msg any) error, parallel bool, framed bool) {
 	mu := sync.Mutex{}
-	wg := sync.WaitGroup{}
+	writeLocked := func(msg any) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return write(msg)
+	}
+	respond := func(resp any) {
+		if err := writeLocked(resp); err != nil {
+			r.logger.Logf("Can't write response: %v", err)
+			writeLocked(ErrorResponse(nil, ErrorFromCode(ErrCodeInternalError)))
+		}
+	}
+	// Handlers are cancelled if graceful shutdown timed out.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func(done <-chan struct{}) {
+		select {
+		case <-r.handlersCtx.Done():
+			cancel()
+		case <-done:
+		}
+	}(ctx.Done())
+	var sess *Session
+	var requests *inflightRequests
+	if !transport.IsUnidirectional(ctx) {
+		sess = newSession(writeLocked)
+		ctx = context.WithValue(ctx, sessionKey{}, sess)
+		defer sess.close()
+		requests = newInflightRequests()
+		ctx = context.WithValue(ctx, inflightRequestsKey{}, requests)
+	}
+	exec := newExecutor(parallel)
+	connInflight := int64(0)
 	for {
-		req := new(RpcRequest)
-		if err := dec.Decode(req); err != nil {
+		msg, err := read()
+		if err == errInvalidJSON {
+			respond(ErrorResponse(nil, ErrorFromCode(ErrCodeParseError)))
+			if framed {
+				continue
+			}
+		}
+		if errors.Is(err, transport.ErrMessageTooLarge) {
+			respond(ErrorResponse(nil, ErrorFromCode(ErrCodeMessageTooLarge)))
+			if framed {
+				continue
+			}
+		}
+		if err != nil {
 			break
 		}
-		exec := func() {
-			h := r.callMethod
-			for _, m := range r.middlewares {
-				h = m(h)
+		if resp, tooDeep := r.checkDepth(msg); tooDeep {
+			if resp != nil {
+				respond(resp)
+			}
+			continue
+		}
+		// Responses to calls made by session are routed immediately,
+		// because handler waiting for them blocks execution of next requests.
+		if sess != nil && sess.deliver(msg) {
+			continue
+		}
+		// Cancellation is handled by reader too, because in sequential mode it would wait for cancelled request.
+		if requests != nil && requests.handle(msg) {
+			continue
+		}
+		// Limits are checked by reader, so rejected messages neither start goroutines nor wait in queue.
+		// Each request of batch takes a slot, because they may be executed in parallel.
+		cost := r.messageCost(msg)
+		if !acquire(&connInflight, cost, r.maxConnConcurrency) {
+			if resp := rejectMessage(msg, TooManyRequests(concurrencyRetryAfter)); resp != nil {
+				respond(resp)
 			}
-			resp := h(ctx, req)
-			if req.Id == nil {
-				// notification request
-				return
+			continue
+		}
+		if !acquire(&r.inflight, cost, r.maxConcurrency) {
+			atomic.AddInt64(&connInflight, -cost)
+			if resp := rejectMessage(msg, TooManyRequests(concurrencyRetryAfter)); resp != nil {
+				respond(resp)
 			}
-			mu.Lock()
-			defer mu.Unlock()
-			if err := enc.Encode(resp); err != nil {
-				r.logger.Logf("Can't write response: %v", err)
-				enc.Encode(ErrorResponse(req.Id, ErrorFromCode(ErrCodeInternalError)))
+			continue
+		}
+		// Requests are registered before they are queued, so they can be cancelled while waiting.
+		unregister := func() {}
+		if requests != nil {
+			unregister = requests.accept(msg)
+		}
+		idle := transport.Busy(ctx)
+		queued := exec.run(func() {
+			defer atomic.AddInt64(&connInflight, -cost)
+			defer atomic.AddInt64(&r.inflight, -cost)
+			defer unregister()
+			defer idle()
+			if resp := r.resolveMessage(ctx, msg, parallel); resp != nil {
+				respond(resp)
 			}
-			if w, canFlush := w.(Flusher); canFlush {
-				w.Flush()
+		})
+		if !queued {
+			unregister()
+			idle()
+			// Reader must not wait for queue, it delivers responses to calls of executing handler.
+			atomic.AddInt64(&connInflight, -cost)
+			atomic.AddInt64(&r.inflight, -cost)
+			if resp := rejectMessage(msg, TooManyRequests(concurrencyRetryAfter)); resp != nil {
+				respond(resp)
 			}
 		}
-		if parallel {
-			wg.Add(1)
-			go func(req *RpcRequest) {
-				defer wg.Done()
-				exec()
-			}(req)
-		} else {
-			exec()
+	}
+	if sess != nil {
+		sess.stopReading()
+	}
+	exec.wait()
+}
+
+// resolveMessage resolves single request or batch of requests.
+// Returns *RpcResponse, []*RpcResponse or nil if there is nothing to respond.
+func (r *RpcServer) resolveMessage(ctx context.Context, msg json.RawMessage, parallel bool) any {
+	if !isBatch(msg) {
+		if resp := r.resolveRequest(ctx, msg); resp != nil {
+			return resp
 		}
+		return nil
+	}
+	batch := []json.RawMessage{}
+	if err := json.Unmarshal(msg, &batch); err != nil || len(batch) == 0 {
+		return ErrorResponse(nil, ErrorFromCode(ErrCodeInvalidRequest))
+	}
+	if r.maxBatchSize > 0 && len(batch) > r.maxBatchSize {
+		return ErrorResponse(nil, NewError("Batch too large", ErrCodeMessageTooLarge))
 	}
+	responses := make([]*RpcResponse, len(batch))
 	if parallel {
+		wg := sync.WaitGroup{}
+		for i, raw := range batch {
+			wg.Add(1)
+			go func(i int, raw json.RawMessage) {
+				defer wg.Done()
+				responses[i] = r.resolveRequest(ctx, raw)
+			}(i, raw)
+		}
 		wg.Wait()
+	} else {
+		for i, raw := range batch {
+			responses[i] = r.resolveRequest(ctx, raw)
+		}
+	}
+	result := make([]*RpcResponse, 0, len(responses))
+	for _, resp := range responses {
+		if resp != nil {
+			result = append(result, resp)
+		}
+	}
+	if len(result) == 0 {
+		// batch of notifications
+		return nil
 	}
+	return result
 }
 
-func (r *RpcServer) callMethod(ctx context.Context, req *RpcRequest) *RpcResponse {
-	r.mu.RLock()
-	h, ok := r.handlers[strings.ToLower(req.Method)]
-	r.mu.RUnlock()
+// resolveRequest executes single request. Returns nil for notifications.
+func (r *RpcServer) resolveRequest(ctx context.Context, msg json.RawMessage) *RpcResponse {
+	req, errResp := parseRequest(msg)
+	if errResp != nil {
+		if isResponse(msg) {
+			// Response from peer must not be answered.
+			if sess, ok := SessionFromContext(ctx); !ok || !sess.deliver(msg) {
+				r.logger.Logf("Unexpected response from peer: %s", msg)
+			}
+			return nil
+		}
+		return errResp
+	}
+	if requests, ok := ctx.Value(inflightRequestsKey{}).(*inflightRequests); ok && !req.IsNotification() {
+		var release func()
+		var started bool
+		ctx, release, started = requests.start(ctx, req.Id)
+		defer release()
+		if !started {
+			return ErrorResponse(req.Id, ErrorFromCode(ErrCodeRequestCancelled))
+		}
+	}
+	resp := r.handle(ctx, req)
+	if req.IsNotification() {
+		return nil
+	}
+	return resp
+}
+
+// handle executes parsed request with server timeout and middlewares.
+func (r *RpcServer) handle(ctx context.Context, req *RpcRequest) *RpcResponse {
+	h, m, ok := r.route(req.Method)
 	if !ok {
-		return ErrorResponse(req.Id, ErrorFromCode(ErrCodeMethodNotFound))
+		h = methodNotFound
 	}
-	resp, err := h(ctx, req.Params)
-	if err != nil {
-		r.logger.Logf("User error %v", err)
-		return ErrorResponse(req.Id, err)
+	timeout := r.methodTimeout(req.Method)
+	if m != nil {
+		ctx = context.WithValue(ctx, methodInfoKey{}, m.info)
+		if m.info.ParamNames != nil {
+			// middlewares (e.g. validation) see params by name regardless of how they were passed
+			params, err := namedParams(req.Params, m.info.ParamNames)
+			if err != nil {
+				return ErrorResponse(req.Id, WrapError(err, "", ErrCodeInvalidParams))
+			}
+			named := *req
+			named.Params = params
+			req = &named
+		}
+		if m.timeout != nil {
+			timeout = *m.timeout
+		}
+	} else if info, found := r.mountedMethodInfo(req.Method); found {
+		ctx = context.WithValue(ctx, methodInfoKey{}, info)
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if m == nil || !m.skipMiddlewares {
+		r.mu.RLock()
+		middlewares := r.middlewares
+		r.mu.RUnlock()
+		for _, mw := range middlewares {
+			h = mw(h)
+		}
+	}
+	return h(ctx, req)
+}
+
+// namedParams converts positional params to params by name. Missing params are converted to empty object.
+func namedParams(params json.RawMessage, names []string) (json.RawMessage, error) {
+	switch {
+	case isAbsent(params):
+		return json.RawMessage("{}"), nil
+	case isBatch(params):
+		return positionalToNamed(params, names)
 	}
+	return params, nil
+}
 
-	return ResultResponse(req.Id, resp)
+func methodNotFound(ctx context.Context, req *RpcRequest) *RpcResponse {
+	return ErrorResponse(req.Id, ErrorFromCode(ErrCodeMethodNotFound))
 }
 
+// methodTimeout returns timeout of method or default timeout.
+func (r *RpcServer) methodTimeout(method string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if timeout, ok := r.methodTimeouts[method]; ok {
+		return timeout
+	}
+	if !r.caseSensitive {
+		for name, timeout := range r.methodTimeouts {
+			if strings.EqualFold(name, method) {
+				return timeout
+			}
+		}
+	}
+	return r.timeout
+}
+
+// contextError converts handler error to rpc error.
+// Errors caused by request timeout or cancellation get corresponding codes unless handler returned rpc error.
+func (r *RpcServer) contextError(ctx context.Context, err error) Error {
+	var rpcErr Error
+	var rpcErrPtr *Error
+	if errors.As(err, &rpcErr) || errors.As(err, &rpcErrPtr) {
+		return toError(err, r.errorMapper)
+	}
+	switch {
+	case cancelledByPeer(ctx):
+		return WrapError(err, "", ErrCodeRequestCancelled)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return WrapError(err, "", ErrCodeTimeout)
+	}
+	return toError(err, r.errorMapper)
+}
+
+// ResultResponse returns successful response. Empty result is sent as null, because response must have result member.
 func ResultResponse(id any, resp json.RawMessage) *RpcResponse {
+	if len(resp) == 0 {
+		resp = json.RawMessage("null")
+	}
 	return &RpcResponse{
 		Jsonrpc: version,
 		Result:  resp,
@@ -153,3 +536,22 @@ func ErrorResponse(id any, err error) *RpcResponse {
 		Id:      id,
 	}
 }
+
+func isBatch(msg json.RawMessage) bool {
+	for _, c := range msg {
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '[':
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+func isParseError(err error) bool {
+	var syntaxErr *json.SyntaxError
+	return errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
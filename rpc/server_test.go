@@ -0,0 +1,331 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.neonxp.dev/jsonrpc2/transport"
+)
+
+// resolve resolves input as stream of JSON values and returns sorted summary of responses (see summarize).
+func resolve(t *testing.T, s *RpcServer, input string, parallel bool) []string {
+	t.Helper()
+	out := &bytes.Buffer{}
+	s.Resolve(context.Background(), strings.NewReader(input), out, parallel)
+	return summarize(t, out.Bytes())
+}
+
+func TestBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		parallel bool
+		want     []string
+	}{
+		{
+			name:  "calls",
+			input: `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping","id":"a"},{"jsonrpc":"2.0","method":"ping","id":2.5}]`,
+			want:  []string{`[1:0 "a":0 2.5:0]`},
+		},
+		{
+			name:  "notifications are not answered",
+			input: `[{"jsonrpc":"2.0","method":"ping","id":1},{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"nope"}]`,
+			want:  []string{`[1:0]`},
+		},
+		{
+			name:  "only notifications",
+			input: `[{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"ping"}]`,
+			want:  []string{},
+		},
+		{
+			name:  "empty",
+			input: `[]`,
+			want:  []string{"null:-32600"},
+		},
+		{
+			name:  "invalid members",
+			input: `[1,{"jsonrpc":"2.0","method":"ping","id":1},"x",{"jsonrpc":"2.0","id":2}]`,
+			want:  []string{`[null:-32600 1:0 null:-32600 2:-32600]`},
+		},
+		{
+			name:  "errors keep order",
+			input: `[{"jsonrpc":"2.0","method":"nope","id":1},{"jsonrpc":"2.0","method":"fail","id":2},{"jsonrpc":"2.0","method":"ping","id":3}]`,
+			want:  []string{`[1:-32601 2:-32603 3:0]`},
+		},
+		{
+			name:  "invalid json",
+			input: `[{"jsonrpc":"2.0","method":"ping","id":1},`,
+			want:  []string{"null:-32700"},
+		},
+		{
+			name:  "batch and single requests",
+			input: `{"jsonrpc":"2.0","method":"ping","id":1}[{"jsonrpc":"2.0","method":"ping","id":2}]`,
+			want:  []string{"1:0", "[2:0]"},
+		},
+		{
+			name:     "parallel keeps order",
+			parallel: true,
+			input:    `[{"jsonrpc":"2.0","method":"sleep","params":{"ms":30},"id":1},{"jsonrpc":"2.0","method":"sleep","params":{"ms":1},"id":2},{"jsonrpc":"2.0","method":"ping"},{"jsonrpc":"2.0","method":"sleep","params":{"ms":10},"id":3}]`,
+			want:     []string{`[1:0 2:0 3:0]`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			s.Register("ping", H(func(ctx context.Context, _ *struct{}) (int, error) { return 1, nil }))
+			s.Register("fail", H(func(ctx context.Context, _ *struct{}) (int, error) { return 0, errors.New("fail") }))
+			s.Register("sleep", H(func(ctx context.Context, p *struct {
+				Ms int `json:"ms"`
+			}) (int, error) {
+				time.Sleep(time.Duration(p.Ms) * time.Millisecond)
+				return p.Ms, nil
+			}))
+			if got := resolve(t, s, tt.input, tt.parallel); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchParallel(t *testing.T) {
+	tests := []struct {
+		name     string
+		parallel bool
+		want     string // requests started before all of batch fail in sequential mode
+	}{
+		{"parallel", true, `[1:0 2:0 3:0]`},
+		{"sequential", false, `[1:-32603 2:-32603 3:0]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New()
+			started := int32(0)
+			// each request waits for all requests of batch to start
+			s.Register("meet", H(func(ctx context.Context, _ *struct{}) (int, error) {
+				atomic.AddInt32(&started, 1)
+				deadline := time.Now().Add(200 * time.Millisecond)
+				for atomic.LoadInt32(&started) < 3 {
+					if time.Now().After(deadline) {
+						return 0, errors.New("not met")
+					}
+					time.Sleep(time.Millisecond)
+				}
+				return 1, nil
+			}))
+			input := `[{"jsonrpc":"2.0","method":"meet","id":1},{"jsonrpc":"2.0","method":"meet","id":2},{"jsonrpc":"2.0","method":"meet","id":3}]`
+			got := resolve(t, s, input, tt.parallel)
+			if len(got) != 1 || got[0] != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilResult(t *testing.T) {
+	s := New()
+	s.Register("nothing", HandlerFunc(func(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+		return nil, nil
+	}))
+	out := &bytes.Buffer{}
+	s.Resolve(context.Background(), strings.NewReader(`{"jsonrpc":"2.0","method":"nothing","id":1}`), out, false)
+	if got := strings.TrimSpace(out.String()); got != `{"jsonrpc":"2.0","result":null,"id":1}` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+// TestSessionCallWithFullQueue checks that reader delivers response to call of handler
+// while client has pipelined more requests than queue holds.
+func TestSessionCallWithFullQueue(t *testing.T) {
+	s := New()
+	s.Register("noop", H(func(ctx context.Context, _ *struct{}) (int, error) { return 0, nil }))
+	s.Register("ask", H(func(ctx context.Context, _ *struct{}) (int, error) {
+		sess, _ := SessionFromContext(ctx)
+		answer := 0
+		err := sess.Call(ctx, "question", nil, &answer)
+		return answer, err
+	}))
+	serverConn, clientConn := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.Resolve(ctx, serverConn, serverConn, false)
+		serverConn.Close()
+	}()
+	clientConn.SetDeadline(time.Now().Add(5 * time.Second))
+	defer clientConn.Close()
+
+	const noops = 2 * maxQueue
+	written := make(chan struct{})
+	results := make(chan testResponse, noops+1)
+	go func() {
+		dec := json.NewDecoder(clientConn)
+		for {
+			msg := struct {
+				testResponse
+				Method string `json:"method"`
+			}{}
+			if err := dec.Decode(&msg); err != nil {
+				close(results)
+				return
+			}
+			if msg.Method == "question" {
+				// answer is sent after all requests, so server must read them first
+				go func(id json.RawMessage) {
+					<-written
+					clientConn.Write([]byte(`{"jsonrpc":"2.0","result":42,"id":` + string(id) + `}`))
+				}(msg.Id)
+				continue
+			}
+			results <- msg.testResponse
+		}
+	}()
+	clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"ask","id":0}`))
+	for i := 1; i <= noops; i++ {
+		if _, err := clientConn.Write([]byte(`{"jsonrpc":"2.0","method":"noop","id":` + strconv.Itoa(i) + `}`)); err != nil {
+			t.Fatalf("write of request %d: %v", i, err)
+		}
+	}
+	close(written)
+
+	answered, rejected := 0, 0
+	for resp := range results {
+		answered++
+		if string(resp.Id) == "0" {
+			if resp.Error != nil || string(resp.Result) != "42" {
+				t.Fatalf("ask response = %s, %v", resp.Result, resp.Error)
+			}
+		} else if resp.Error != nil {
+			if resp.Error.Code != ErrCodeTooManyRequests {
+				t.Fatalf("noop error = %v", resp.Error)
+			}
+			rejected++
+		}
+		if answered == noops+1 {
+			break
+		}
+	}
+	if answered != noops+1 {
+		t.Fatalf("%d responses, want %d", answered, noops+1)
+	}
+	if rejected == 0 {
+		t.Fatal("no requests rejected by full queue")
+	}
+}
+
+// stdioConn is connection of stdio transport, client writes to in and reads from out.
+type stdioConn struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (c stdioConn) Close() error {
+	c.PipeReader.Close()
+	return c.PipeWriter.Close()
+}
+
+func TestShutdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		handler  func(ctx context.Context) (int, error)
+		wantErr  error
+		wantCode int // code of error response, 0 for result
+	}{
+		{
+			name:    "drains in-flight requests",
+			timeout: 2 * time.Second,
+			handler: func(ctx context.Context) (int, error) {
+				time.Sleep(100 * time.Millisecond)
+				return 1, nil
+			},
+		},
+		{
+			name:    "cancels handlers on deadline",
+			timeout: 50 * time.Millisecond,
+			handler: func(ctx context.Context) (int, error) {
+				<-ctx.Done()
+				return 0, ctx.Err()
+			},
+			wantErr:  context.DeadlineExceeded,
+			wantCode: ErrCodeInternalError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inR, inW := io.Pipe()
+			outR, outW := io.Pipe()
+			s := New()
+			s.Use(WithTransport(&transport.Stdio{Conn: stdioConn{inR, outW}}))
+			started := make(chan struct{})
+			s.Register("work", H(func(ctx context.Context, _ *struct{}) (int, error) {
+				close(started)
+				return tt.handler(ctx)
+			}))
+			done := make(chan error, 1)
+			go func() { done <- s.Run(context.Background()) }()
+			responses := make(chan testResponse, 1)
+			go func() {
+				resp := testResponse{}
+				if json.NewDecoder(outR).Decode(&resp) == nil {
+					responses <- resp
+				}
+				close(responses)
+			}()
+
+			io.WriteString(inW, `{"jsonrpc":"2.0","method":"work","id":1}`+"\n")
+			<-started
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			if err := s.Shutdown(ctx); err != tt.wantErr {
+				t.Fatalf("Shutdown() error = %v, want %v", err, tt.wantErr)
+			}
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Run() did not return")
+			}
+			resp, ok := <-responses
+			if !ok {
+				t.Fatal("no response")
+			}
+			code := 0
+			if resp.Error != nil {
+				code = resp.Error.Code
+			}
+			if string(resp.Id) != "1" || code != tt.wantCode {
+				t.Fatalf("response to %s with error %v, want code %d", resp.Id, resp.Error, tt.wantCode)
+			}
+		})
+	}
+}
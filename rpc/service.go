@@ -0,0 +1,212 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NameMapper converts name of Go method to name of rpc method.
+type NameMapper func(name string) string
+
+// LowerCamelCase maps "GetUser" to "getUser". It is default NameMapper of services.
+func LowerCamelCase(name string) string {
+	runes := []rune(name)
+	// leading acronym is lowered entirely: "HTTPStatus" -> "httpStatus"
+	for i := 0; i < len(runes) && unicode.IsUpper(runes[i]); i++ {
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// SnakeCase maps "GetHTTPStatus" to "get_http_status".
+func SnakeCase(name string) string {
+	runes := []rune(name)
+	b := strings.Builder{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+// ServiceOption configures registration of service.
+type ServiceOption func(o *serviceOptions)
+
+type serviceOptions struct {
+	mapper        NameMapper
+	methodOptions []MethodOption
+}
+
+// WithNameMapper sets function converting names of Go methods to names of rpc methods (default LowerCamelCase).
+func WithNameMapper(mapper NameMapper) ServiceOption {
+	return func(o *serviceOptions) {
+		o.mapper = mapper
+	}
+}
+
+// WithMethodOptions sets options applied to every method of service.
+func WithMethodOptions(opts ...MethodOption) ServiceOption {
+	return func(o *serviceOptions) {
+		o.methodOptions = append(o.methodOptions, opts...)
+	}
+}
+
+// RegisterService registers exported methods of svc as "prefix.method". Supported signatures are:
+//
+//	func (s *Service) Method(ctx context.Context, req *Request) (Response, error)
+//	func (s *Service) Method(ctx context.Context, req Request) (Response, error)
+//	func (s *Service) Method(ctx context.Context) (Response, error)
+//
+// Params are decoded like by H. If any exported method has other signature, nothing is registered
+// and error is returned. Empty prefix registers methods without namespace.
+func (r *RpcServer) RegisterService(prefix string, svc any, opts ...ServiceOption) error {
+	return r.registerService(prefix, svc, nil, opts)
+}
+
+// RegisterService registers exported methods of svc as "groupPrefix.prefix.method". See RpcServer.RegisterService.
+func (g *Group) RegisterService(prefix string, svc any, opts ...ServiceOption) error {
+	return g.server.registerService(joinMethod(g.prefix, prefix), svc, g, opts)
+}
+
+func (r *RpcServer) registerService(prefix string, svc any, group *Group, opts []ServiceOption) error {
+	o := serviceOptions{mapper: LowerCamelCase}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	v := reflect.ValueOf(svc)
+	if !v.IsValid() {
+		return fmt.Errorf("jsonrpc2: service %s is nil", prefix)
+	}
+	t := v.Type()
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).NumMethod() > t.NumMethod() {
+		return fmt.Errorf("jsonrpc2: service %s has methods with pointer receiver, pass pointer to %s", prefix, t)
+	}
+	if t.NumMethod() == 0 {
+		return fmt.Errorf("jsonrpc2: service %s (%s) has no exported methods", prefix, t)
+	}
+	handlers := map[string]Handler{}
+	names := []string{}
+	problems := []string{}
+	for i := 0; i < t.NumMethod(); i++ {
+		m := t.Method(i)
+		h, err := serviceHandler(v.Method(i))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s.%s: %v", t, m.Name, err))
+			continue
+		}
+		name := joinMethod(prefix, o.mapper(m.Name))
+		handlers[name] = h
+		names = append(names, name)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("jsonrpc2: service %s has unsupported methods: %s", prefix, strings.Join(problems, "; "))
+	}
+	// names are checked against each other and registered methods under single lock,
+	// so service is registered entirely or not at all
+	methods := make([]*method, 0, len(names))
+	keys := map[string]string{}
+	for _, name := range names {
+		key := r.methodKey(name)
+		if other, ok := keys[key]; ok {
+			return fmt.Errorf("%w: %s collides with %s", ErrMethodExists, name, other)
+		}
+		keys[key] = name
+		methods = append(methods, newMethod(name, handlers[name], group, o.methodOptions))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, name := range names {
+		if err := r.checkCollision(name); err != nil {
+			return err
+		}
+	}
+	for _, m := range methods {
+		r.addMethod(m)
+	}
+	return nil
+}
+
+// serviceHandler makes handler of bound method.
+func serviceHandler(fn reflect.Value) (Handler, error) {
+	ft := fn.Type()
+	if ft.IsVariadic() || ft.NumIn() < 1 || ft.NumIn() > 2 || ft.In(0) != contextType {
+		return nil, fmt.Errorf("expected func(context.Context[, params]) (result, error), got %s", ft)
+	}
+	if ft.NumOut() != 2 || ft.Out(1) != errorType {
+		return nil, fmt.Errorf("expected (result, error) results, got %s", ft)
+	}
+	result := ft.Out(0)
+	if ft.NumIn() == 1 {
+		return typedHandler{
+			HandlerFunc: func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
+				return callService(fn, []reflect.Value{reflect.ValueOf(ctx)})
+			},
+			result: result,
+		}, nil
+	}
+	in := ft.In(1)
+	params, isPtr := in, in.Kind() == reflect.Ptr
+	if isPtr {
+		params = in.Elem()
+	}
+	names := paramNames(params)
+	return typedHandler{
+		HandlerFunc: func(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+			req := reflect.New(params)
+			if err := decodeParams(raw, req.Interface(), names); err != nil {
+				return nil, WrapError(err, "", ErrCodeInvalidParams)
+			}
+			if !isPtr {
+				req = req.Elem()
+			}
+			return callService(fn, []reflect.Value{reflect.ValueOf(ctx), req})
+		},
+		params: params,
+		result: result,
+	}, nil
+}
+
+func callService(fn reflect.Value, args []reflect.Value) (json.RawMessage, error) {
+	out := fn.Call(args)
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+	return json.Marshal(out[0].Interface())
+}
@@ -0,0 +1,103 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type collidingService struct{}
+
+func (collidingService) Echo(ctx context.Context, s string) (string, error) { return s, nil }
+func (collidingService) Get(ctx context.Context) (int, error)               { return 1, nil }
+func (collidingService) GET(ctx context.Context) (int, error)               { return 2, nil }
+
+type echoService struct{}
+
+func (echoService) Echo(ctx context.Context, s string) (string, error) { return s, nil }
+
+func TestRegisterServiceCollisions(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		svcOpts []ServiceOption
+		prepare func(r *RpcServer) error
+		svc     any
+		wantErr bool
+	}{
+		{
+			name:    "methods of service collide",
+			svc:     collidingService{},
+			wantErr: true,
+		},
+		{
+			name:    "mapped names of service are equal",
+			opts:    []Option{WithCaseSensitiveMethods()},
+			svc:     collidingService{},
+			wantErr: true,
+		},
+		{
+			name:    "methods of service differ in case sensitive mode",
+			opts:    []Option{WithCaseSensitiveMethods()},
+			svcOpts: []ServiceOption{WithNameMapper(func(name string) string { return name })},
+			svc:     collidingService{},
+			wantErr: false,
+		},
+		{
+			name: "method collides with registered one",
+			prepare: func(r *RpcServer) error {
+				return r.Register("svc.ECHO", H(func(ctx context.Context, p *struct{}) (int, error) { return 0, nil }))
+			},
+			svc:     echoService{},
+			wantErr: true,
+		},
+		{
+			name:    "no collisions",
+			svc:     echoService{},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := New(tt.opts...)
+			if tt.prepare != nil {
+				if err := tt.prepare(r); err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := len(r.Methods())
+			err := r.RegisterService("svc", tt.svc, tt.svcOpts...)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("RegisterService() error = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMethodExists) {
+				t.Fatalf("RegisterService() error = %v, want ErrMethodExists", err)
+			}
+			if after := len(r.Methods()); after != before {
+				t.Fatalf("%d methods registered by failed RegisterService()", after-before)
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+//Package rpc provides abstract rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+)
+
+// ErrSessionClosed returned by session methods after connection was closed.
+var ErrSessionClosed = errors.New("jsonrpc2: session is closed")
+
+type sessionKey struct{}
+
+// SessionFromContext returns session of connection that request came from.
+// Sessions are available only on persistent connections (TCP, unix socket, etc).
+func SessionFromContext(ctx context.Context) (*Session, bool) {
+	sess, ok := ctx.Value(sessionKey{}).(*Session)
+	return sess, ok
+}
+
+// Session is a connection with remote peer. Handlers or any other goroutines
+// may use it to send notifications and calls to peer while connection is alive.
+type Session struct {
+	write   func(msg any) error
+	mu      sync.Mutex
+	lastId  uint64
+	pending map[string]chan *peerResponse
+	closed  bool
+	readEnd chan struct{}
+	done    chan struct{}
+}
+
+func newSession(write func(msg any) error) *Session {
+	return &Session{
+		write:   write,
+		pending: map[string]chan *peerResponse{},
+		readEnd: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Done returns channel that is closed when connection is closed.
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// Notify sends notification to peer.
+func (s *Session) Notify(ctx context.Context, method string, params any) error {
+	req, err := newPeerRequest(method, params, nil)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return ErrSessionClosed
+	}
+	return s.write(req)
+}
+
+// Call calls method of peer and decodes its result to result (if it is not nil).
+// Error returned by peer is rpc.Error.
+func (s *Session) Call(ctx context.Context, method string, params any, result any) error {
+	s.mu.Lock()
+	if s.pending == nil {
+		s.mu.Unlock()
+		return ErrSessionClosed
+	}
+	s.lastId++
+	id := s.lastId
+	key := strconv.FormatUint(id, 10)
+	ch := make(chan *peerResponse, 1)
+	s.pending[key] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+	}()
+
+	req, err := newPeerRequest(method, params, id)
+	if err != nil {
+		return err
+	}
+	if err := s.write(req); err != nil {
+		return err
+	}
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return *resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-s.readEnd:
+		return ErrSessionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// deliver passes response (or batch of responses) to waiting calls.
+// Returns false if msg is not a response to session call.
+func (s *Session) deliver(msg json.RawMessage) bool {
+	s.mu.Lock()
+	waiting := len(s.pending) > 0
+	s.mu.Unlock()
+	if !waiting || !isResponse(msg) {
+		return false
+	}
+	if isBatch(msg) {
+		batch := []json.RawMessage{}
+		if err := json.Unmarshal(msg, &batch); err != nil {
+			return false
+		}
+		for _, m := range batch {
+			s.deliver(m)
+		}
+		return true
+	}
+	resp := new(peerResponse)
+	if err := json.Unmarshal(msg, resp); err != nil {
+		return false
+	}
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, resp.Id); err != nil {
+		return false
+	}
+	s.mu.Lock()
+	ch, ok := s.pending[buf.String()]
+	delete(s.pending, buf.String())
+	s.mu.Unlock()
+	if ok {
+		ch <- resp
+	}
+	return ok
+}
+
+// stopReading fails all pending calls, because no more responses can be received.
+func (s *Session) stopReading() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	close(s.readEnd)
+}
+
+func (s *Session) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	close(s.done)
+}
+
+type peerRequest struct {
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      any             `json:"id,omitempty"`
+}
+
+func newPeerRequest(method string, params any, id any) (*peerRequest, error) {
+	req := &peerRequest{
+		Jsonrpc: version,
+		Method:  method,
+		Id:      id,
+	}
+	if params != nil {
+		p, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		req.Params = p
+	}
+	return req, nil
+}
+
+type peerResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *Error          `json:"error"`
+	Id     json.RawMessage `json:"id"`
+}
+
+// isResponse reports whether msg is a response object (or batch of them) rather than request.
+func isResponse(msg json.RawMessage) bool {
+	if isBatch(msg) {
+		batch := []json.RawMessage{}
+		if err := json.Unmarshal(msg, &batch); err != nil || len(batch) == 0 {
+			return false
+		}
+		for _, m := range batch {
+			if !isResponse(m) {
+				return false
+			}
+		}
+		return true
+	}
+	probe := struct {
+		Method json.RawMessage `json:"method"`
+		Result json.RawMessage `json:"result"`
+		Error  json.RawMessage `json:"error"`
+		Id     json.RawMessage `json:"id"`
+	}{}
+	if err := json.Unmarshal(msg, &probe); err != nil {
+		return false
+	}
+	return probe.Method == nil && probe.Id != nil && (probe.Result != nil || probe.Error != nil)
+}
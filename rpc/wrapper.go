@@ -22,37 +22,125 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"reflect"
 )
 
-func H[RQ any, RS any](handler func(context.Context, *RQ) (RS, error)) HandlerFunc {
-	return func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
+// H is a generic wrapper for rpc handlers with request params.
+// Errors returned by handler are passed to server as is, so rpc.Error keeps its code and data.
+// If RQ is struct, params are accepted both by name and by position (in order of struct fields).
+// Missing params leave RQ zero.
+func H[RQ any, RS any](handler func(context.Context, *RQ) (RS, error)) TypedHandler {
+	names := paramNames(typeOf[RQ]())
+	fn := func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
 		req := new(RQ)
-		if err := json.Unmarshal(in, req); err != nil {
-			return nil, ErrorFromCode(ErrCodeParseError)
+		if err := decodeParams(in, req, names); err != nil {
+			return nil, WrapError(err, "", ErrCodeInvalidParams)
 		}
 		resp, err := handler(ctx, req)
 		if err != nil {
-			return nil, Error{
-				Code:    ErrUser,
-				Message: err.Error(),
-			}
+			return nil, err
 		}
 		return json.Marshal(resp)
 	}
+	return typedHandler{
+		HandlerFunc: fn,
+		params:      typeOf[RQ](),
+		result:      typeOf[RS](),
+	}
 }
 
 // HS is a simple generic wrapper for rpc handlers without any request params.
-func HS[RS any](handler func(context.Context) (RS, error)) HandlerFunc {
-	return func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
+func HS[RS any](handler func(context.Context) (RS, error)) TypedHandler {
+	fn := func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
 		resp, err := handler(ctx)
 		if err != nil {
-			return nil, Error{
-				Code:    ErrUser,
-				Message: err.Error(),
-			}
+			return nil, err
 		}
 		return json.Marshal(resp)
 	}
+	return typedHandler{
+		HandlerFunc: fn,
+		result:      typeOf[RS](),
+	}
+}
+
+// H2 is a generic wrapper for rpc handlers with two params. Params are accepted both by position ([a, b])
+// and by name ({"param1": a, "param2": b}). Names of params may be set by names (param1, param2 by default).
+// Missing params are zero.
+func H2[A, B, RS any](handler func(context.Context, A, B) (RS, error), names ...string) TypedHandler {
+	params := paramsStruct(names, typeOf[A](), typeOf[B]())
+	return positional(params, typeOf[RS](), func(ctx context.Context, args []reflect.Value) (any, error) {
+		return handler(ctx, valueAs[A](args[0]), valueAs[B](args[1]))
+	})
+}
+
+// H3 is a generic wrapper for rpc handlers with three params. See H2.
+func H3[A, B, C, RS any](handler func(context.Context, A, B, C) (RS, error), names ...string) TypedHandler {
+	params := paramsStruct(names, typeOf[A](), typeOf[B](), typeOf[C]())
+	return positional(params, typeOf[RS](), func(ctx context.Context, args []reflect.Value) (any, error) {
+		return handler(ctx, valueAs[A](args[0]), valueAs[B](args[1]), valueAs[C](args[2]))
+	})
+}
+
+// positional makes handler decoding params to fields of params struct and passing them as separate arguments.
+func positional(params, result reflect.Type, call func(context.Context, []reflect.Value) (any, error)) TypedHandler {
+	names := paramNames(params)
+	fn := func(ctx context.Context, in json.RawMessage) (json.RawMessage, error) {
+		args := reflect.New(params)
+		if err := decodeParams(in, args.Interface(), names); err != nil {
+			return nil, WrapError(err, "", ErrCodeInvalidParams)
+		}
+		values := make([]reflect.Value, params.NumField())
+		for i := range values {
+			values[i] = args.Elem().Field(i)
+		}
+		resp, err := call(ctx, values)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(resp)
+	}
+	return typedHandler{
+		HandlerFunc: fn,
+		params:      params,
+		result:      result,
+	}
+}
+
+// valueAs returns value as T. Nil interfaces are returned as zero T.
+func valueAs[T any](v reflect.Value) T {
+	t, _ := v.Interface().(T)
+	return t
+}
+
+// Handler resolves params of method call to result.
+type Handler interface {
+	Handle(ctx context.Context, params json.RawMessage) (json.RawMessage, error)
+}
+
+// TypedHandler is handler that knows Go types of its params and result.
+// Handlers made by H and HS implement it, so server can describe them (see RpcServer.Methods).
+type TypedHandler interface {
+	Handler
+	// Types returns types of params (nil if handler has no params) and result.
+	Types() (params, result reflect.Type)
 }
 
 type HandlerFunc func(context.Context, json.RawMessage) (json.RawMessage, error)
+
+func (f HandlerFunc) Handle(ctx context.Context, params json.RawMessage) (json.RawMessage, error) {
+	return f(ctx, params)
+}
+
+type typedHandler struct {
+	HandlerFunc
+	params, result reflect.Type
+}
+
+func (h typedHandler) Types() (params, result reflect.Type) {
+	return h.params, h.result
+}
+
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
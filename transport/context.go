@@ -0,0 +1,124 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+)
+
+type (
+	unidirectionalKey struct{}
+	tlsStateKey       struct{}
+	metadataKey       struct{}
+	activityKey       struct{}
+)
+
+// Metadata describes connection or HTTP request that message came from.
+// Transports attach it to context passed to resolver, so middlewares and handlers can read it.
+type Metadata struct {
+	Transport  string               // Name of transport: "http", "tcp", "unix", "websocket", "stdio"
+	RemoteAddr string               // Optional address of peer
+	LocalAddr  string               // Optional address connection was accepted on
+	Header     http.Header          // Optional headers of HTTP request (or WebSocket handshake)
+	Body       []byte               // Optional raw body of HTTP request, e.g. to verify its signature
+	TLS        *tls.ConnectionState // Optional TLS state of connection
+	Peer       *PeerCredentials     // Optional credentials of process on other end of unix socket (linux only)
+}
+
+// PeerCredentials are credentials of peer process of unix socket connection (SO_PEERCRED).
+type PeerCredentials struct {
+	PID int
+	UID int
+	GID int
+}
+
+// WithMetadata attaches metadata of connection or request to context.
+func WithMetadata(ctx context.Context, md *Metadata) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// MetadataFromContext returns metadata of connection or request that message came from.
+func MetadataFromContext(ctx context.Context) (*Metadata, bool) {
+	md, ok := ctx.Value(metadataKey{}).(*Metadata)
+	return md, ok && md != nil
+}
+
+// Unidirectional marks connection context as request-response only (like HTTP).
+// Server can't send own notifications and calls to peer over such connection.
+func Unidirectional(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unidirectionalKey{}, true)
+}
+
+// IsUnidirectional reports whether connection context was marked by Unidirectional.
+func IsUnidirectional(ctx context.Context) bool {
+	v, _ := ctx.Value(unidirectionalKey{}).(bool)
+	return v
+}
+
+// activity is implemented by connections closed after idle timeout.
+type activity interface {
+	begin()
+	end()
+}
+
+func withActivity(ctx context.Context, a activity) context.Context {
+	return context.WithValue(ctx, activityKey{}, a)
+}
+
+// Busy marks connection of ctx as busy until returned function is called, so it isn't closed by idle timeout.
+// Resolvers mark connection busy while they resolve its requests.
+func Busy(ctx context.Context) func() {
+	a, ok := ctx.Value(activityKey{}).(activity)
+	if !ok {
+		return func() {}
+	}
+	a.begin()
+	return a.end
+}
+
+// WithTLSState attaches TLS state of connection to context.
+func WithTLSState(ctx context.Context, state *tls.ConnectionState) context.Context {
+	return context.WithValue(ctx, tlsStateKey{}, state)
+}
+
+// TLSState returns TLS state of connection that request came from.
+// It is taken from WithTLSState or Metadata.
+func TLSState(ctx context.Context) (*tls.ConnectionState, bool) {
+	if state, ok := ctx.Value(tlsStateKey{}).(*tls.ConnectionState); ok && state != nil {
+		return state, true
+	}
+	if md, ok := MetadataFromContext(ctx); ok && md.TLS != nil {
+		return md.TLS, true
+	}
+	return nil, false
+}
+
+// ClientCertificate returns verified certificate of client (mutual TLS) or nil.
+// Certificates not verified against ClientCAs (e.g. with tls.RequestClientCert) are ignored.
+func ClientCertificate(ctx context.Context) *x509.Certificate {
+	state, ok := TLSState(ctx)
+	if !ok || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
@@ -0,0 +1,55 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestClientCertificate(t *testing.T) {
+	leaf, ca := &x509.Certificate{}, &x509.Certificate{}
+	tests := []struct {
+		name  string
+		state *tls.ConnectionState
+		want  *x509.Certificate
+	}{
+		{"no tls", nil, nil},
+		{"no certificate", &tls.ConnectionState{}, nil},
+		{"not verified", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}, nil},
+		{"verified", &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{leaf},
+			VerifiedChains:   [][]*x509.Certificate{{leaf, ca}},
+		}, leaf},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.state != nil {
+				ctx = WithMetadata(ctx, &Metadata{TLS: tt.state})
+			}
+			if got := ClientCertificate(ctx); got != tt.want {
+				t.Fatalf("ClientCertificate() = %p, want %p", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Framer splits byte stream to separate messages (frames).
+type Framer interface {
+	NewReader(r io.Reader) FrameReader
+	NewWriter(w io.Writer) FrameWriter
+}
+
+type FrameReader interface {
+	// ReadFrame returns payload of next frame.
+	ReadFrame() ([]byte, error)
+}
+
+type FrameWriter interface {
+	// WriteFrame writes p as single frame.
+	WriteFrame(p []byte) error
+}
+
+// defaultMaxFrameSize limits frames if resolver has no size limit,
+// so peer can't make reader allocate arbitrary amount of memory by announced length.
+const defaultMaxFrameSize = 32 << 20
+
+// maxHeaderLine limits length of header line (e.g. "Content-Length: 123").
+const maxHeaderLine = 4096
+
+// frameSizeLimiter is implemented by frame readers able to skip frames over size limit.
+// Such frames are reported by ErrMessageTooLarge, and reading may continue with next frame.
+type frameSizeLimiter interface {
+	setMaxSize(n int64)
+}
+
+// NewlineFramer frames messages as newline delimited JSON (one message per line).
+type NewlineFramer struct{}
+
+func (NewlineFramer) NewReader(r io.Reader) FrameReader {
+	return &newlineReader{rd: bufio.NewReader(r), max: defaultMaxFrameSize}
+}
+
+func (NewlineFramer) NewWriter(w io.Writer) FrameWriter {
+	return &newlineWriter{w: w}
+}
+
+type newlineReader struct {
+	rd  *bufio.Reader
+	max int64
+}
+
+func (r *newlineReader) setMaxSize(n int64) {
+	r.max = n
+}
+
+func (r *newlineReader) ReadFrame() ([]byte, error) {
+	for {
+		line, err := r.readLine()
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readLine reads next line. Line longer than max is skipped and ErrMessageTooLarge is returned.
+func (r *newlineReader) readLine() ([]byte, error) {
+	line := []byte{}
+	skipping := false
+	for {
+		chunk, err := r.rd.ReadSlice('\n')
+		if !skipping {
+			line = append(line, chunk...)
+			// leave room for "\r\n"
+			skipping = int64(len(line)) > r.max+2
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if skipping || int64(len(bytes.TrimSpace(line))) > r.max {
+			if err == nil {
+				err = ErrMessageTooLarge
+			}
+			return nil, err
+		}
+		return line, err
+	}
+}
+
+type newlineWriter struct {
+	w io.Writer
+}
+
+func (w *newlineWriter) WriteFrame(p []byte) error {
+	buf := make([]byte, 0, len(p)+1)
+	buf = append(buf, trimNewline(p)...)
+	buf = append(buf, '\n')
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// ContentLengthFramer frames messages with "Content-Length" header like Language Server Protocol does.
+type ContentLengthFramer struct{}
+
+func (ContentLengthFramer) NewReader(r io.Reader) FrameReader {
+	return &contentLengthReader{rd: bufio.NewReader(r), max: defaultMaxFrameSize}
+}
+
+func (ContentLengthFramer) NewWriter(w io.Writer) FrameWriter {
+	return &contentLengthWriter{w: w}
+}
+
+var errMissingContentLength = errors.New("framing: missing Content-Length header")
+
+type contentLengthReader struct {
+	rd  *bufio.Reader
+	max int64
+}
+
+func (r *contentLengthReader) setMaxSize(n int64) {
+	r.max = n
+}
+
+func (r *contentLengthReader) ReadFrame() ([]byte, error) {
+	length := -1
+	for {
+		line, err := readDelim(r.rd, '\n', maxHeaderLine)
+		if err != nil {
+			if err == io.EOF && line != "" {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			if length < 0 {
+				// skip empty lines between messages
+				continue
+			}
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("framing: invalid header %q", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || length < 0 {
+				return nil, fmt.Errorf("framing: invalid Content-Length %q", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errMissingContentLength
+	}
+	if int64(length) > r.max {
+		return nil, skip(r.rd, int64(length))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r.rd, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+type contentLengthWriter struct {
+	w io.Writer
+}
+
+func (w *contentLengthWriter) WriteFrame(p []byte) error {
+	p = trimNewline(p)
+	buf := bytes.NewBufferString("Content-Length: " + strconv.Itoa(len(p)) + "\r\n\r\n")
+	buf.Write(p)
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+// LengthPrefixFramer frames messages with 4 byte big endian length prefix.
+type LengthPrefixFramer struct{}
+
+func (LengthPrefixFramer) NewReader(r io.Reader) FrameReader {
+	return &lengthPrefixReader{rd: bufio.NewReader(r), max: defaultMaxFrameSize}
+}
+
+func (LengthPrefixFramer) NewWriter(w io.Writer) FrameWriter {
+	return &lengthPrefixWriter{w: w}
+}
+
+type lengthPrefixReader struct {
+	rd  *bufio.Reader
+	max int64
+}
+
+func (r *lengthPrefixReader) setMaxSize(n int64) {
+	r.max = n
+}
+
+func (r *lengthPrefixReader) ReadFrame() ([]byte, error) {
+	prefix := make([]byte, 4)
+	if _, err := io.ReadFull(r.rd, prefix); err != nil {
+		return nil, err
+	}
+	length := binary.BigEndian.Uint32(prefix)
+	if int64(length) > r.max {
+		return nil, skip(r.rd, int64(length))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r.rd, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+type lengthPrefixWriter struct {
+	w io.Writer
+}
+
+func (w *lengthPrefixWriter) WriteFrame(p []byte) error {
+	p = trimNewline(p)
+	buf := make([]byte, 4, 4+len(p))
+	binary.BigEndian.PutUint32(buf, uint32(len(p)))
+	buf = append(buf, p...)
+	_, err := w.w.Write(buf)
+	return err
+}
+
+// NetstringFramer frames messages as netstrings ("<length>:<payload>,").
+type NetstringFramer struct{}
+
+func (NetstringFramer) NewReader(r io.Reader) FrameReader {
+	return &netstringReader{rd: bufio.NewReader(r), max: defaultMaxFrameSize}
+}
+
+func (NetstringFramer) NewWriter(w io.Writer) FrameWriter {
+	return &netstringWriter{w: w}
+}
+
+type netstringReader struct {
+	rd  *bufio.Reader
+	max int64
+}
+
+func (r *netstringReader) setMaxSize(n int64) {
+	r.max = n
+}
+
+func (r *netstringReader) ReadFrame() ([]byte, error) {
+	// length of int64 is at most 19 digits
+	prefix, err := readDelim(r.rd, ':', 32)
+	if err != nil {
+		if err == io.EOF && prefix != "" {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	prefix = strings.TrimSpace(strings.TrimSuffix(prefix, ":"))
+	length, err := strconv.Atoi(prefix)
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("framing: invalid netstring length %q", prefix)
+	}
+	if int64(length) > r.max {
+		// payload and trailing comma
+		return nil, skip(r.rd, int64(length)+1)
+	}
+	payload := make([]byte, length+1)
+	if _, err := io.ReadFull(r.rd, payload); err != nil {
+		return nil, err
+	}
+	if payload[length] != ',' {
+		return nil, errors.New("framing: netstring must end with comma")
+	}
+	return payload[:length], nil
+}
+
+type netstringWriter struct {
+	w io.Writer
+}
+
+func (w *netstringWriter) WriteFrame(p []byte) error {
+	p = trimNewline(p)
+	buf := bytes.NewBufferString(strconv.Itoa(len(p)) + ":")
+	buf.Write(p)
+	buf.WriteByte(',')
+	_, err := w.w.Write(buf.Bytes())
+	return err
+}
+
+// frameStreamReader presents frames as stream of JSON values for Resolver.
+type frameStreamReader struct {
+	fr  FrameReader
+	buf []byte
+}
+
+func (r *frameStreamReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		frame, err := r.fr.ReadFrame()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = append(frame, '\n')
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// frameStreamWriter writes every Write call as separate frame.
+// Resolver writes each message with single Write call.
+type frameStreamWriter struct {
+	fw FrameWriter
+}
+
+func (w *frameStreamWriter) Write(p []byte) (int, error) {
+	if err := w.fw.WriteFrame(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// readDelim reads until delim like bufio.Reader.ReadString, but fails if more than max bytes are read.
+// It is used for headers of frames, which are small.
+func readDelim(rd *bufio.Reader, delim byte, max int) (string, error) {
+	line := []byte{}
+	for {
+		chunk, err := rd.ReadSlice(delim)
+		line = append(line, chunk...)
+		if len(line) > max {
+			return "", fmt.Errorf("framing: header is longer than %d bytes", max)
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		return string(line), err
+	}
+}
+
+// skip discards n bytes of frame over size limit, so next frame can be read.
+func skip(rd io.Reader, n int64) error {
+	if _, err := io.CopyN(io.Discard, rd, n); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return ErrMessageTooLarge
+}
+
+func trimNewline(p []byte) []byte {
+	for len(p) > 0 && (p[len(p)-1] == '\n' || p[len(p)-1] == '\r') {
+		p = p[:len(p)-1]
+	}
+	return p
+}
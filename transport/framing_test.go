@@ -0,0 +1,270 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type frameResult struct {
+	frame string
+	err   error // nil if any error except ErrMessageTooLarge and io.EOF is expected
+}
+
+// readFrames reads frames until error that is not ErrMessageTooLarge.
+func readFrames(fr FrameReader) []frameResult {
+	results := []frameResult{}
+	for {
+		frame, err := fr.ReadFrame()
+		if err == nil {
+			results = append(results, frameResult{frame: string(frame)})
+			continue
+		}
+		if errors.Is(err, ErrMessageTooLarge) || err == io.EOF {
+			results = append(results, frameResult{err: err})
+		} else {
+			results = append(results, frameResult{})
+		}
+		if !errors.Is(err, ErrMessageTooLarge) {
+			return results
+		}
+	}
+}
+
+func prefixed(payload string, length uint32) string {
+	prefix := make([]byte, 4)
+	binary.BigEndian.PutUint32(prefix, length)
+	return string(prefix) + payload
+}
+
+func TestFrameReaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		framer Framer
+		input  string
+		max    int64
+		want   []frameResult
+	}{
+		{
+			name:   "newline frames",
+			framer: NewlineFramer{},
+			input:  "{}\n\n  \r\n[1]\r\n{\"a\":1}",
+			want:   []frameResult{{frame: "{}"}, {frame: "[1]"}, {frame: `{"a":1}`}, {err: io.EOF}},
+		},
+		{
+			name:   "newline oversized line is skipped",
+			framer: NewlineFramer{},
+			max:    4,
+			input:  "[1,2]\n" + strings.Repeat("x", 10000) + "\n{}\n",
+			want:   []frameResult{{err: ErrMessageTooLarge}, {err: ErrMessageTooLarge}, {frame: "{}"}, {err: io.EOF}},
+		},
+		{
+			name:   "newline long line without limit of resolver",
+			framer: NewlineFramer{},
+			input:  strings.Repeat("x", defaultMaxFrameSize+1) + "\n{}\n",
+			want:   []frameResult{{err: ErrMessageTooLarge}, {frame: "{}"}, {err: io.EOF}},
+		},
+		{
+			name:   "content-length frames",
+			framer: ContentLengthFramer{},
+			input:  "Content-Length: 2\r\n\r\n{}\r\n\r\ncontent-length:3\r\nContent-Type: x\r\n\r\n[1]",
+			want:   []frameResult{{frame: "{}"}, {frame: "[1]"}, {err: io.EOF}},
+		},
+		{
+			name:   "content-length truncated payload",
+			framer: ContentLengthFramer{},
+			input:  "Content-Length: 10\r\n\r\n{}",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length truncated header",
+			framer: ContentLengthFramer{},
+			input:  "Content-Length: 2",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length missing length",
+			framer: ContentLengthFramer{},
+			input:  "Content-Type: x\r\n\r\n{}",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length invalid length",
+			framer: ContentLengthFramer{},
+			input:  "Content-Length: -1\r\n\r\n{}",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length invalid header",
+			framer: ContentLengthFramer{},
+			input:  "{}\r\n\r\n",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length long header",
+			framer: ContentLengthFramer{},
+			input:  "X-Pad: " + strings.Repeat("a", maxHeaderLine) + "\r\n",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length huge length without limit of resolver",
+			framer: ContentLengthFramer{},
+			input:  "Content-Length: 4611686018427387904\r\n\r\n{}",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "content-length oversized frame is skipped",
+			framer: ContentLengthFramer{},
+			max:    4,
+			input:  "Content-Length: 5\r\n\r\n[1,2]Content-Length: 2\r\n\r\n{}",
+			want:   []frameResult{{err: ErrMessageTooLarge}, {frame: "{}"}, {err: io.EOF}},
+		},
+		{
+			name:   "length prefix frames",
+			framer: LengthPrefixFramer{},
+			input:  prefixed("{}", 2) + prefixed("[1]", 3) + prefixed("", 0),
+			want:   []frameResult{{frame: "{}"}, {frame: "[1]"}, {frame: ""}, {err: io.EOF}},
+		},
+		{
+			name:   "length prefix truncated payload",
+			framer: LengthPrefixFramer{},
+			input:  prefixed("{}", 10),
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "length prefix truncated prefix",
+			framer: LengthPrefixFramer{},
+			input:  "\x00\x00",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "length prefix huge length without limit of resolver",
+			framer: LengthPrefixFramer{},
+			input:  prefixed("{}", 0xFFFFFFFF),
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "length prefix oversized frame is skipped",
+			framer: LengthPrefixFramer{},
+			max:    4,
+			input:  prefixed("[1,2]", 5) + prefixed("{}", 2),
+			want:   []frameResult{{err: ErrMessageTooLarge}, {frame: "{}"}, {err: io.EOF}},
+		},
+		{
+			name:   "netstring frames",
+			framer: NetstringFramer{},
+			input:  "2:{},\n3:[1],0:,",
+			want:   []frameResult{{frame: "{}"}, {frame: "[1]"}, {frame: ""}, {err: io.EOF}},
+		},
+		{
+			name:   "netstring truncated payload",
+			framer: NetstringFramer{},
+			input:  "10:{},",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring truncated prefix",
+			framer: NetstringFramer{},
+			input:  "12",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring missing comma",
+			framer: NetstringFramer{},
+			input:  "2:{}]",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring invalid length",
+			framer: NetstringFramer{},
+			input:  "x:{},",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring long prefix",
+			framer: NetstringFramer{},
+			input:  strings.Repeat("1", 1000) + ":",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring huge length without limit of resolver",
+			framer: NetstringFramer{},
+			input:  "4611686018427387904:{},",
+			want:   []frameResult{{}},
+		},
+		{
+			name:   "netstring oversized frame is skipped",
+			framer: NetstringFramer{},
+			max:    4,
+			input:  "5:[1,2],2:{},",
+			want:   []frameResult{{err: ErrMessageTooLarge}, {frame: "{}"}, {err: io.EOF}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := tt.framer.NewReader(strings.NewReader(tt.input))
+			if tt.max > 0 {
+				fr.(frameSizeLimiter).setMaxSize(tt.max)
+			}
+			got := readFrames(fr)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFrameWriters(t *testing.T) {
+	tests := []struct {
+		name   string
+		framer Framer
+		want   string
+	}{
+		{"newline", NewlineFramer{}, "{}\n"},
+		{"content-length", ContentLengthFramer{}, "Content-Length: 2\r\n\r\n{}"},
+		{"length prefix", LengthPrefixFramer{}, prefixed("{}", 2)},
+		{"netstring", NetstringFramer{}, "2:{},"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := tt.framer.NewWriter(buf).WriteFrame([]byte("{}\n")); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+			frame, err := tt.framer.NewReader(buf).ReadFrame()
+			if err != nil || string(frame) != "{}" {
+				t.Fatalf("read back %q, %v", frame, err)
+			}
+		})
+	}
+}
@@ -20,52 +20,165 @@
 package transport
 
 import (
+	"bytes"
 	"context"
 	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
+	"sync"
 )
 
 type HTTP struct {
 	Bind       string
-	TLS        *tls.Config
+	TLS        *tls.Config // Optional TLS config. Set GetCertificate to reload certificates without restart.
+	CertFile   string      // Optional certificate file, enables HTTPS
+	KeyFile    string      // Optional private key file, enables HTTPS
 	CORSOrigin string
 	Parallel   bool
+
+	mu  sync.Mutex
+	srv *http.Server
 }
 
 func (h *HTTP) Run(ctx context.Context, resolver Resolver) error {
-	srv := http.Server{
+	srv := &http.Server{
 		Addr: h.Bind,
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodOptions && h.CORSOrigin != "" {
-				w.Header().Set("Access-Control-Allow-Origin", h.CORSOrigin)
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-				w.WriteHeader(http.StatusOK)
-				return
-			}
-			if r.Method != http.MethodPost {
-				w.WriteHeader(http.StatusMethodNotAllowed)
-				return
-			}
-			if h.CORSOrigin != "" {
-				w.Header().Set("Access-Control-Allow-Origin", h.CORSOrigin)
-				w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-			}
-			w.Header().Add("Content-Type", "application/json")
-			w.WriteHeader(http.StatusOK)
-			resolver.Resolve(ctx, r.Body, w, h.Parallel)
+		Handler: NewHTTPHandler(resolver, HTTPOptions{
+			CORSOrigin: h.CORSOrigin,
+			Parallel:   h.Parallel,
 		}),
 		BaseContext: func(l net.Listener) context.Context {
 			return ctx
 		},
 		TLSConfig: h.TLS,
 	}
+	h.mu.Lock()
+	h.srv = srv
+	h.mu.Unlock()
 	go func() {
 		<-ctx.Done()
 		srv.Close()
 	}()
-	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+	var err error
+	if h.TLS != nil || h.CertFile != "" {
+		err = srv.ListenAndServeTLS(h.CertFile, h.KeyFile)
+	} else {
+		err = srv.ListenAndServe()
+	}
+	if err != http.ErrServerClosed {
 		return err
 	}
 	return nil
 }
+
+// Shutdown stops accepting connections and waits until in-flight requests are resolved.
+func (h *HTTP) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	srv := h.srv
+	h.mu.Unlock()
+	if srv == nil {
+		return nil
+	}
+	return srv.Shutdown(ctx)
+}
+
+type HTTPOptions struct {
+	CORSOrigin string
+	Parallel   bool
+}
+
+// NewHTTPHandler returns JSON-RPC endpoint that can be mounted on any router or server.
+// Handlers receive context of http request.
+func NewHTTPHandler(resolver Resolver, opts HTTPOptions) http.Handler {
+	return &httpHandler{
+		resolver: resolver,
+		opts:     opts,
+	}
+}
+
+type httpHandler struct {
+	resolver Resolver
+	opts     HTTPOptions
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodOptions && h.opts.CORSOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", h.opts.CORSOrigin)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if h.opts.CORSOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", h.opts.CORSOrigin)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	}
+	// Body is read entirely, so it is available in metadata (e.g. to verify signature).
+	limit := maxMessageSize(h.resolver)
+	if limit > 0 && r.ContentLength > limit {
+		h.tooLarge(w, r)
+		return
+	}
+	body, err := readBody(r.Body, limit)
+	if err == ErrMessageTooLarge {
+		h.tooLarge(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, "can't read body", http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	ctx := Unidirectional(r.Context())
+	ctx = WithMetadata(ctx, &Metadata{
+		Transport:  "http",
+		RemoteAddr: r.RemoteAddr,
+		LocalAddr:  localAddr(r),
+		Header:     r.Header,
+		Body:       body,
+		TLS:        r.TLS,
+	})
+	h.resolver.Resolve(ctx, bytes.NewReader(body), w, h.opts.Parallel)
+}
+
+// tooLarge responds with status 413 and JSON-RPC error made by resolver.
+func (h *httpHandler) tooLarge(w http.ResponseWriter, r *http.Request) {
+	// rest of body is not read, so connection can't be reused
+	w.Header().Set("Connection", "close")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusRequestEntityTooLarge)
+	h.resolver.Resolve(Unidirectional(r.Context()), errReader{err: ErrMessageTooLarge}, w, false)
+}
+
+// readBody reads body up to limit bytes. Zero limit means no limit.
+func readBody(body io.Reader, limit int64) ([]byte, error) {
+	if limit <= 0 {
+		return io.ReadAll(body)
+	}
+	b, err := io.ReadAll(io.LimitReader(body, limit+1))
+	if err == nil && int64(len(b)) > limit {
+		return nil, ErrMessageTooLarge
+	}
+	return b, err
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// localAddr returns address that HTTP request was accepted on.
+func localAddr(r *http.Request) string {
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		return addr.String()
+	}
+	return ""
+}
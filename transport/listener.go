@@ -0,0 +1,283 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	tlsHandshakeTimeout = 10 * time.Second
+	minAcceptDelay      = 5 * time.Millisecond
+	maxAcceptDelay      = time.Second
+)
+
+// streamConfig is configuration of connections shared by TCP and unix socket transports.
+type streamConfig struct {
+	tls            *tls.Config
+	framer         Framer
+	parallel       bool
+	idleTimeout    time.Duration
+	writeTimeout   time.Duration
+	maxConnections int
+	onConnect      func(ctx context.Context, conn net.Conn) (context.Context, error)
+	onDisconnect   func(ctx context.Context, conn net.Conn)
+}
+
+// streamServer serves connections accepted from listener. It is shared by TCP and unix socket transports.
+type streamServer struct {
+	mu       sync.Mutex
+	ln       net.Listener
+	conns    map[*serverConn]struct{}
+	wg       sync.WaitGroup
+	shutdown bool
+}
+
+// serve accepts connections and resolves each of them in separate goroutine.
+// Temporary accept errors (e.g. too many open files) are retried with backoff.
+func (s *streamServer) serve(ctx context.Context, ln net.Listener, resolver Resolver, cfg streamConfig) error {
+	if cfg.tls != nil {
+		ln = tls.NewListener(ln, cfg.tls)
+	}
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return ln.Close()
+	}
+	s.ln = ln
+	s.conns = map[*serverConn]struct{}{}
+	s.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+	delay := time.Duration(0)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || s.isShutdown() {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				delay *= 2
+				if delay < minAcceptDelay {
+					delay = minAcceptDelay
+				}
+				if delay > maxAcceptDelay {
+					delay = maxAcceptDelay
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+				}
+				continue
+			}
+			return err
+		}
+		delay = 0
+		sc := &serverConn{Conn: conn, idleTimeout: cfg.idleTimeout, writeTimeout: cfg.writeTimeout}
+		if !s.track(sc, cfg.maxConnections) {
+			conn.Close()
+			continue
+		}
+		go func() {
+			defer s.untrack(sc)
+			serveConn(ctx, sc, resolver, cfg)
+		}()
+	}
+}
+
+// Shutdown closes listener and stops reading new requests from connections.
+// Connections are closed after their in-flight requests are resolved or forcibly when ctx is done.
+func (s *streamServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	if s.ln != nil {
+		_ = s.ln.Close()
+	}
+	for conn := range s.conns {
+		// unblocks reading, so resolver finishes in-flight requests and returns
+		conn.drain()
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// track registers connection unless server is shut down or has max connections (zero means no limit).
+func (s *streamServer) track(conn *serverConn, max int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.shutdown || (max > 0 && len(s.conns) >= max) {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *streamServer) untrack(conn *serverConn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+func (s *streamServer) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+func serveConn(ctx context.Context, conn *serverConn, resolver Resolver, cfg streamConfig) {
+	defer conn.Close()
+	md := &Metadata{
+		Transport:  conn.LocalAddr().Network(),
+		RemoteAddr: conn.RemoteAddr().String(),
+		LocalAddr:  conn.LocalAddr().String(),
+		Peer:       peerCredentials(conn.Conn),
+	}
+	if tlsConn, ok := conn.Conn.(*tls.Conn); ok {
+		hsCtx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+		err := tlsConn.HandshakeContext(hsCtx)
+		cancel()
+		if err != nil {
+			return
+		}
+		state := tlsConn.ConnectionState()
+		md.TLS = &state
+	}
+	ctx = WithMetadata(ctx, md)
+	if cfg.onConnect != nil {
+		connCtx, err := cfg.onConnect(ctx, conn)
+		if err != nil {
+			return
+		}
+		if connCtx != nil {
+			ctx = connCtx
+		}
+	}
+	if cfg.onDisconnect != nil {
+		defer cfg.onDisconnect(ctx, conn)
+	}
+	resolveStream(withActivity(ctx, conn), resolver, cfg.framer, conn, cfg.parallel)
+}
+
+// serverConn is accepted connection. It applies idle and write timeouts.
+type serverConn struct {
+	net.Conn
+	idleTimeout  time.Duration
+	writeTimeout time.Duration
+	mu           sync.Mutex // guards read deadline and fields below
+	draining     bool
+	busy         int       // requests being resolved and writes in progress
+	lastActive   time.Time // when connection stopped being busy
+}
+
+// Read extends idle deadline before reading, so connection without incoming data is closed after idle timeout.
+// Connection is not closed while it is busy or during idle timeout after that.
+func (c *serverConn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	if c.draining {
+		c.mu.Unlock()
+		return 0, io.EOF
+	}
+	if c.idleTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+	}
+	c.mu.Unlock()
+	for {
+		n, err := c.Conn.Read(p)
+		if n > 0 || err == nil || !c.extendIdle(err) {
+			return n, err
+		}
+	}
+}
+
+// extendIdle extends read deadline if err is idle timeout of connection that is or was recently busy.
+func (c *serverConn) extendIdle(err error) bool {
+	if ne, ok := err.(net.Error); !ok || !ne.Timeout() || c.idleTimeout <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.draining {
+		return false
+	}
+	deadline := c.lastActive.Add(c.idleTimeout)
+	if c.busy > 0 {
+		deadline = time.Now().Add(c.idleTimeout)
+	}
+	if !deadline.After(time.Now()) {
+		return false
+	}
+	_ = c.Conn.SetReadDeadline(deadline)
+	return true
+}
+
+func (c *serverConn) Write(p []byte) (int, error) {
+	c.begin()
+	defer c.end()
+	if c.writeTimeout > 0 {
+		_ = c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *serverConn) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy++
+}
+
+func (c *serverConn) end() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.busy--
+	c.lastActive = time.Now()
+}
+
+// drain stops reading, so resolver finishes in-flight requests and returns.
+func (c *serverConn) drain() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.draining = true
+	_ = c.Conn.SetReadDeadline(time.Now())
+}
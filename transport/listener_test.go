@@ -0,0 +1,311 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// funcResolver resolves each frame by function in separate goroutine.
+type funcResolver func(ctx context.Context, frame []byte, w FrameWriter)
+
+func (f funcResolver) Resolve(ctx context.Context, r io.Reader, w io.Writer, parallel bool) {}
+
+func (f funcResolver) ResolveFrames(ctx context.Context, r FrameReader, w FrameWriter, parallel bool) {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	for {
+		frame, err := r.ReadFrame()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(ctx, frame, w)
+		}()
+	}
+}
+
+func echo(ctx context.Context, frame []byte, w FrameWriter) {
+	_ = w.WriteFrame(frame)
+}
+
+// listen serves connections of TCP listener with cfg until end of test and returns its address.
+func listen(t *testing.T, resolver Resolver, cfg streamConfig) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.framer = NewlineFramer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- (&streamServer{}).serve(ctx, ln, resolver, cfg) }()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("serve error = %v", err)
+		}
+	})
+	return ln.Addr().String()
+}
+
+// dial connects to addr and returns connection and reader of its lines.
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	t.Cleanup(func() { conn.Close() })
+	return conn, bufio.NewReader(conn)
+}
+
+// roundTrip writes line and returns line read back.
+func roundTrip(conn net.Conn, rd *bufio.Reader, line string) (string, error) {
+	if _, err := io.WriteString(conn, line+"\n"); err != nil {
+		return "", err
+	}
+	got, err := rd.ReadString('\n')
+	if len(got) > 0 {
+		got = got[:len(got)-1]
+	}
+	return got, err
+}
+
+func TestIdleTimeout(t *testing.T) {
+	const idle = 50 * time.Millisecond
+	resolver := funcResolver(func(ctx context.Context, frame []byte, w FrameWriter) {
+		switch string(frame) {
+		case "slow":
+			done := Busy(ctx)
+			defer done()
+			time.Sleep(4 * idle)
+		case "push":
+			// server notifications not related to requests
+			for i := 0; i < 6; i++ {
+				time.Sleep(idle / 2)
+				_ = w.WriteFrame(frame)
+			}
+			return
+		}
+		_ = w.WriteFrame(frame)
+	})
+	tests := []struct {
+		name  string
+		lines []string // lines written by client, next one after 2 idle timeouts
+		want  []string // sorted lines read before connection is closed
+	}{
+		{"idle connection is closed", []string{"fast"}, []string{"fast"}},
+		{"busy connection is not closed", []string{"slow", "fast"}, []string{"fast", "slow"}},
+		{"writes keep connection open", []string{"push", "fast"}, []string{"fast", "push", "push", "push", "push", "push", "push"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := listen(t, resolver, streamConfig{idleTimeout: idle})
+			conn, rd := dial(t, addr)
+			go func() {
+				for i, line := range tt.lines {
+					if i > 0 {
+						time.Sleep(2 * idle)
+					}
+					if _, err := io.WriteString(conn, line+"\n"); err != nil {
+						return
+					}
+				}
+			}()
+			got := []string{}
+			start := time.Now()
+			for {
+				line, err := rd.ReadString('\n')
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("read error = %v, lines %v", err, got)
+				}
+				got = append(got, line[:len(line)-1])
+			}
+			// order of pushes and responses is not defined
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Fatalf("lines %v, want %v", got, tt.want)
+			}
+			if elapsed := time.Since(start); elapsed > 2*time.Second {
+				t.Fatalf("connection closed after %v", elapsed)
+			}
+		})
+	}
+}
+
+func TestMaxConnections(t *testing.T) {
+	addr := listen(t, funcResolver(echo), streamConfig{maxConnections: 1})
+	first, firstRd := dial(t, addr)
+	if got, err := roundTrip(first, firstRd, "first"); err != nil || got != "first" {
+		t.Fatalf("first connection: %q, %v", got, err)
+	}
+	second, secondRd := dial(t, addr)
+	if _, err := roundTrip(second, secondRd, "second"); err == nil {
+		t.Fatal("connection over limit is served")
+	}
+	first.Close()
+	// slot is released when server notices closed connection
+	for i := 0; ; i++ {
+		conn, rd := dial(t, addr)
+		got, err := roundTrip(conn, rd, "third")
+		if err == nil && got == "third" {
+			break
+		}
+		if i == 100 {
+			t.Fatalf("connection after release: %q, %v", got, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+type ctxKey struct{}
+
+func TestConnectHooks(t *testing.T) {
+	disconnected := make(chan any, 1)
+	addr := listen(t, funcResolver(func(ctx context.Context, frame []byte, w FrameWriter) {
+		_ = w.WriteFrame([]byte(ctx.Value(ctxKey{}).(string)))
+	}), streamConfig{
+		onConnect: func(ctx context.Context, conn net.Conn) (context.Context, error) {
+			if _, ok := MetadataFromContext(ctx); !ok {
+				return nil, errors.New("no metadata")
+			}
+			if conn.RemoteAddr() == nil {
+				return nil, errors.New("no remote address")
+			}
+			return context.WithValue(ctx, ctxKey{}, "state of "+conn.LocalAddr().Network()), nil
+		},
+		onDisconnect: func(ctx context.Context, conn net.Conn) {
+			disconnected <- ctx.Value(ctxKey{})
+		},
+	})
+	conn, rd := dial(t, addr)
+	if got, err := roundTrip(conn, rd, "x"); err != nil || got != "state of tcp" {
+		t.Fatalf("got %q, %v, want state of connection", got, err)
+	}
+	conn.Close()
+	select {
+	case v := <-disconnected:
+		if v != "state of tcp" {
+			t.Fatalf("OnDisconnect got %v", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnDisconnect is not called")
+	}
+}
+
+func TestConnectHookError(t *testing.T) {
+	disconnected := make(chan struct{}, 1)
+	addr := listen(t, funcResolver(echo), streamConfig{
+		onConnect: func(ctx context.Context, conn net.Conn) (context.Context, error) {
+			return nil, errors.New("rejected")
+		},
+		onDisconnect: func(ctx context.Context, conn net.Conn) {
+			disconnected <- struct{}{}
+		},
+	})
+	conn, rd := dial(t, addr)
+	if _, err := roundTrip(conn, rd, "x"); err == nil {
+		t.Fatal("rejected connection is served")
+	}
+	select {
+	case <-disconnected:
+		t.Fatal("OnDisconnect is called for rejected connection")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+// failingListener fails accepts with temporary errors until it is closed and records their times.
+type failingListener struct {
+	net.Listener
+	mu      sync.Mutex
+	accepts []time.Time
+	closed  chan struct{}
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.closed:
+		return nil, net.ErrClosed
+	default:
+		l.accepts = append(l.accepts, time.Now())
+		return nil, temporaryError{}
+	}
+}
+
+func (l *failingListener) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	select {
+	case <-l.closed:
+	default:
+		close(l.closed)
+	}
+	return nil
+}
+
+func TestAcceptBackoff(t *testing.T) {
+	ln := &failingListener{closed: make(chan struct{})}
+	server := &streamServer{}
+	done := make(chan error, 1)
+	go func() { done <- server.serve(context.Background(), ln, funcResolver(echo), streamConfig{}) }()
+	time.Sleep(200 * time.Millisecond)
+	if err := server.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown error = %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("serve error = %v", err)
+	}
+	ln.mu.Lock()
+	defer ln.mu.Unlock()
+	// delays 5ms, 10ms, 20ms, 40ms, 80ms fit in 200ms
+	if n := len(ln.accepts); n < 4 || n > 8 {
+		t.Fatalf("%d accepts in 200ms, want backoff", n)
+	}
+	for i := 2; i < len(ln.accepts); i++ {
+		prev, cur := ln.accepts[i-1].Sub(ln.accepts[i-2]), ln.accepts[i].Sub(ln.accepts[i-1])
+		if cur < minAcceptDelay || cur < prev {
+			t.Fatalf("delays %v and %v after temporary errors, want growing", prev, cur)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+)
+
+// Stdio transport serves single connection over stdin/stdout (or any other stream),
+// like language servers and plugins do.
+type Stdio struct {
+	Conn     io.ReadWriteCloser // Optional connection (default os.Stdin and os.Stdout)
+	Framer   Framer             // Optional message framing (default NewlineFramer)
+	Parallel bool
+
+	mu       sync.Mutex
+	reader   *stoppableReader
+	done     chan struct{}
+	shutdown bool
+}
+
+// Run serves connection until it reaches EOF or ctx is cancelled.
+// After cancellation it stops reading and waits for in-flight requests, whose contexts are cancelled too.
+func (s *Stdio) Run(ctx context.Context, resolver Resolver) error {
+	conn := s.Conn
+	if conn == nil {
+		conn = stdio{}
+	}
+	framer := s.Framer
+	if framer == nil {
+		framer = NewlineFramer{}
+	}
+	rd, done := newStoppableReader(conn), make(chan struct{})
+	s.mu.Lock()
+	if s.shutdown {
+		s.mu.Unlock()
+		return nil
+	}
+	s.reader, s.done = rd, done
+	s.mu.Unlock()
+	go func() {
+		defer close(done)
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{rd, conn}
+		resolveStream(WithMetadata(ctx, &Metadata{Transport: "stdio"}), resolver, framer, rw, s.Parallel)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		rd.stop()
+		<-done
+	}
+	_ = conn.Close()
+	return nil
+}
+
+// Shutdown stops reading of new requests and waits until in-flight requests are resolved or ctx is done.
+func (s *Stdio) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.shutdown = true
+	rd, done := s.reader, s.done
+	s.mu.Unlock()
+	if rd == nil {
+		return nil
+	}
+	rd.stop()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// stoppableReader reads from r in separate goroutine, so reading can be stopped
+// even if Read of r can't be interrupted (like reading of stdin).
+type stoppableReader struct {
+	pr *io.PipeReader
+	pw *io.PipeWriter
+}
+
+func newStoppableReader(r io.Reader) *stoppableReader {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := io.Copy(pw, r)
+		_ = pw.CloseWithError(err)
+	}()
+	return &stoppableReader{pr: pr, pw: pw}
+}
+
+func (r *stoppableReader) Read(p []byte) (int, error) {
+	return r.pr.Read(p)
+}
+
+// stop makes Read return io.EOF. Data read from r but not yet consumed is dropped.
+func (r *stoppableReader) stop() {
+	_ = r.pw.Close()
+}
+
+type stdio struct{}
+
+func (stdio) Read(p []byte) (int, error) {
+	return os.Stdin.Read(p)
+}
+
+func (stdio) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+func (stdio) Close() error {
+	return os.Stdin.Close()
+}
@@ -0,0 +1,103 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// pipeConn is connection of transport, client writes to in and reads from out.
+type pipeConn struct {
+	*io.PipeReader
+	*io.PipeWriter
+}
+
+func (c pipeConn) Close() error {
+	c.PipeReader.Close()
+	return c.PipeWriter.Close()
+}
+
+func newPipeConn() (conn pipeConn, in *io.PipeWriter, out *io.PipeReader) {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	return pipeConn{inR, outW}, inW, outR
+}
+
+func TestStdio(t *testing.T) {
+	tests := []struct {
+		name string
+		stop func(s *Stdio, cancel context.CancelFunc) error
+	}{
+		{"shutdown", func(s *Stdio, cancel context.CancelFunc) error {
+			ctx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+			defer cancelShutdown()
+			return s.Shutdown(ctx)
+		}},
+		{"cancel", func(s *Stdio, cancel context.CancelFunc) error {
+			cancel()
+			return nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, in, out := newPipeConn()
+			s := &Stdio{Conn: conn}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- s.Run(ctx, echoResolver{}) }()
+
+			if _, err := io.WriteString(in, "{}\n"); err != nil {
+				t.Fatal(err)
+			}
+			line, err := bufio.NewReader(out).ReadString('\n')
+			if err != nil || line != "{}\n" {
+				t.Fatalf("echo = %q, %v", line, err)
+			}
+			// reading of next request is blocked
+			if err := tt.stop(s, cancel); err != nil {
+				t.Fatalf("stop error = %v", err)
+			}
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Run() did not return")
+			}
+		})
+	}
+}
+
+func TestStdioShutdownBeforeRun(t *testing.T) {
+	conn, _, _ := newPipeConn()
+	s := &Stdio{Conn: conn}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Run(context.Background(), echoResolver{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,102 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader keeps certificate loaded from files and reloads it after files are changed.
+// Use its GetCertificate method in tls.Config to rotate certificates without restart.
+type CertReloader struct {
+	certFile string
+	keyFile  string
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	modTime  time.Time
+}
+
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	c := &CertReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload loads certificate from files.
+func (c *CertReloader) Reload() error {
+	modTime, err := c.lastModified()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cert = &cert
+	c.modTime = modTime
+	return nil
+}
+
+// GetCertificate returns current certificate. Certificate is reloaded if files are changed.
+// If new certificate can't be loaded, previous one is used.
+func (c *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	cert, loaded := c.cert, c.modTime
+	c.mu.RUnlock()
+	if modTime, err := c.lastModified(); err == nil && modTime.After(loaded) {
+		if err := c.Reload(); err == nil {
+			c.mu.RLock()
+			cert = c.cert
+			c.mu.RUnlock()
+		}
+	}
+	return cert, nil
+}
+
+// TLSConfig returns server config that uses reloader for certificates.
+func (c *CertReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		GetCertificate: c.GetCertificate,
+	}
+}
+
+func (c *CertReloader) lastModified() (time.Time, error) {
+	var last time.Time
+	for _, name := range []string{c.certFile, c.keyFile} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if fi.ModTime().After(last) {
+			last = fi.ModTime()
+		}
+	}
+	return last, nil
+}
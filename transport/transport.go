@@ -21,13 +21,68 @@ package transport
 
 import (
 	"context"
+	"errors"
 	"io"
 )
 
+// ErrMessageTooLarge is returned by readers of transports when incoming message exceeds size limit of resolver.
+var ErrMessageTooLarge = errors.New("message too large")
+
 type Transport interface {
 	Run(ctx context.Context, resolver Resolver) error
 }
 
+// Shutdowner is implemented by transports supporting graceful shutdown.
+// Shutdown must stop accepting new connections and requests and wait until in-flight requests
+// are resolved or ctx is done. Run must return nil after shutdown.
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Resolver resolves requests read from reader. Transports pass Metadata of connection in ctx (see WithMetadata).
 type Resolver interface {
 	Resolve(ctx context.Context, reader io.Reader, writer io.Writer, isParallel bool)
 }
+
+// FrameResolver is implemented by resolvers able to process framed messages one by one.
+// Transports with message framing prefer it over Resolve, so invalid frame costs only this frame.
+type FrameResolver interface {
+	ResolveFrames(ctx context.Context, reader FrameReader, writer FrameWriter, isParallel bool)
+}
+
+// MessageSizeLimiter is implemented by resolvers limiting size of incoming messages.
+// Transports stop reading message as soon as it exceeds limit.
+type MessageSizeLimiter interface {
+	MaxMessageSize() int64
+}
+
+// maxMessageSize returns size limit of resolver or zero if it has no limit.
+func maxMessageSize(resolver Resolver) int64 {
+	if l, ok := resolver.(MessageSizeLimiter); ok {
+		return l.MaxMessageSize()
+	}
+	return 0
+}
+
+// resolveStream resolves stream using framer. Nil framer means stream of concatenated JSON values.
+func resolveStream(ctx context.Context, resolver Resolver, framer Framer, conn io.ReadWriter, isParallel bool) {
+	if framer == nil {
+		resolver.Resolve(ctx, conn, conn, isParallel)
+		return
+	}
+	reader := framer.NewReader(conn)
+	if limit := maxMessageSize(resolver); limit > 0 {
+		if l, ok := reader.(frameSizeLimiter); ok {
+			l.setMaxSize(limit)
+		}
+	}
+	resolveFrames(ctx, resolver, reader, framer.NewWriter(conn), isParallel)
+}
+
+func resolveFrames(ctx context.Context, resolver Resolver, reader FrameReader, writer FrameWriter, isParallel bool) {
+	if fr, ok := resolver.(FrameResolver); ok {
+		fr.ResolveFrames(ctx, reader, writer, isParallel)
+		return
+	}
+	resolver.Resolve(ctx, &frameStreamReader{fr: reader}, &frameStreamWriter{fw: writer}, isParallel)
+}
@@ -20,12 +20,27 @@ package transport
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"time"
 )
 
 type UnixSocket struct {
 	Path     string
+	TLS      *tls.Config // Optional TLS config. Set ClientAuth and ClientCAs for mutual TLS.
+	Framer   Framer      // Optional message framing (default stream of concatenated JSON values)
 	Parallel bool
+
+	IdleTimeout    time.Duration // Optional time without incoming data and in-flight requests or writes after which connection is closed (default no timeout)
+	WriteTimeout   time.Duration // Optional timeout of writing response (default no timeout)
+	MaxConnections int           // Optional max number of connections, others are closed after accept (default no limit)
+	// Optional hook called when connection is accepted. Returned context is passed to handlers of connection,
+	// so it may carry per-connection state. Error closes connection.
+	OnConnect func(ctx context.Context, conn net.Conn) (context.Context, error)
+	// Optional hook called when connection is closed, ctx is context returned by OnConnect.
+	OnDisconnect func(ctx context.Context, conn net.Conn)
+
+	server streamServer
 }
 
 func (t *UnixSocket) Run(ctx context.Context, resolver Resolver) error {
@@ -37,16 +52,19 @@ func (t *UnixSocket) Run(ctx context.Context, resolver Resolver) error {
 	if err != nil {
 		return err
 	}
+	return t.server.serve(ctx, ln, resolver, streamConfig{
+		tls:            t.TLS,
+		framer:         t.Framer,
+		parallel:       t.Parallel,
+		idleTimeout:    t.IdleTimeout,
+		writeTimeout:   t.WriteTimeout,
+		maxConnections: t.MaxConnections,
+		onConnect:      t.OnConnect,
+		onDisconnect:   t.OnDisconnect,
+	})
+}
 
-	go func() {
-		<-ctx.Done()
-		_ = ln.Close()
-	}()
-	for {
-		conn, err := ln.Accept()
-		if err != nil {
-			return err
-		}
-		go resolver.Resolve(ctx, conn, conn, t.Parallel)
-	}
+// Shutdown stops accepting connections and waits until in-flight requests are resolved.
+func (t *UnixSocket) Shutdown(ctx context.Context) error {
+	return t.server.Shutdown(ctx)
 }
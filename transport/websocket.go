@@ -0,0 +1,529 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	wsGUID                  = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	wsDefaultMaxMessageSize = 1 << 20
+	wsWriteTimeout          = 10 * time.Second
+)
+
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+const (
+	wsCloseNormal        = 1000
+	wsCloseGoingAway     = 1001
+	wsCloseProtocolError = 1002
+	wsCloseTooBig        = 1009
+)
+
+var (
+	errWSProtocol = errors.New("websocket: protocol error")
+	errWSTooBig   = fmt.Errorf("websocket: %w", ErrMessageTooLarge)
+)
+
+// WebSocket transport. Each websocket message carries exactly one JSON-RPC request (response) or batch.
+type WebSocket struct {
+	Bind           string
+	Path           string         // Optional path of endpoint (default "/")
+	Mux            *http.ServeMux // Optional existing mux to mount endpoint on. Bind is ignored if set.
+	CORSOrigin     string         // Allowed origin ("*" - any, empty - same origin only)
+	Parallel       bool
+	MaxMessageSize int64         // Optional max size of incoming message (default 1MB or limit of resolver if it is less)
+	PingInterval   time.Duration // Optional keepalive ping interval (default disabled)
+
+	mu       sync.Mutex
+	srv      *http.Server
+	conns    map[*wsConn]struct{}
+	wg       sync.WaitGroup
+	shutdown bool
+}
+
+func (ws *WebSocket) Run(ctx context.Context, resolver Resolver) error {
+	path := ws.Path
+	if path == "" {
+		path = "/"
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.serve(ctx, resolver, w, r)
+	})
+	if ws.Mux != nil {
+		ws.Mux.Handle(path, handler)
+		<-ctx.Done()
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, handler)
+	srv := &http.Server{
+		Addr:    ws.Bind,
+		Handler: mux,
+		BaseContext: func(l net.Listener) context.Context {
+			return ctx
+		},
+	}
+	ws.mu.Lock()
+	ws.srv = srv
+	ws.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// Shutdown stops accepting connections and reading new requests from open connections.
+// Connections are closed after their in-flight requests are resolved or forcibly when ctx is done.
+func (ws *WebSocket) Shutdown(ctx context.Context) error {
+	ws.mu.Lock()
+	ws.shutdown = true
+	srv := ws.srv
+	ws.mu.Unlock()
+	if srv != nil {
+		// closes listener, hijacked connections are not affected
+		if err := srv.Shutdown(ctx); err != nil {
+			return err
+		}
+	}
+	ws.mu.Lock()
+	for conn := range ws.conns {
+		conn.drain()
+	}
+	ws.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		ws.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		ws.mu.Lock()
+		for conn := range ws.conns {
+			conn.close(wsCloseGoingAway)
+		}
+		ws.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (ws *WebSocket) track(conn *wsConn) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.shutdown {
+		return false
+	}
+	if ws.conns == nil {
+		ws.conns = map[*wsConn]struct{}{}
+	}
+	ws.conns[conn] = struct{}{}
+	ws.wg.Add(1)
+	return true
+}
+
+func (ws *WebSocket) untrack(conn *wsConn) {
+	ws.mu.Lock()
+	delete(ws.conns, conn)
+	ws.mu.Unlock()
+	ws.wg.Done()
+}
+
+func (ws *WebSocket) serve(ctx context.Context, resolver Resolver, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		http.Error(w, "invalid websocket key", http.StatusBadRequest)
+		return
+	}
+	if !ws.checkOrigin(r) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	ws.mu.Lock()
+	shutdown := ws.shutdown
+	ws.mu.Unlock()
+	if shutdown {
+		http.Error(w, "server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket is not supported", http.StatusInternalServerError)
+		return
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer netConn.Close()
+	handshake := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + wsAcceptKey(key) + "\r\n\r\n"
+	if _, err := brw.WriteString(handshake); err != nil {
+		return
+	}
+	if err := brw.Flush(); err != nil {
+		return
+	}
+
+	maxSize := ws.MaxMessageSize
+	if maxSize <= 0 {
+		maxSize = wsDefaultMaxMessageSize
+	}
+	if limit := maxMessageSize(resolver); limit > 0 && limit < maxSize {
+		maxSize = limit
+	}
+	conn := &wsConn{
+		conn:        netConn,
+		rd:          brw.Reader,
+		wr:          brw.Writer,
+		maxSize:     maxSize,
+		idleTimeout: 2 * ws.PingInterval,
+		done:        make(chan struct{}),
+	}
+	defer close(conn.done)
+	if !ws.track(conn) {
+		conn.close(wsCloseGoingAway)
+		return
+	}
+	defer ws.untrack(conn)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.close(wsCloseGoingAway)
+		case <-conn.done:
+		}
+	}()
+	if ws.PingInterval > 0 {
+		go conn.keepalive(ws.PingInterval)
+	}
+	connCtx := WithMetadata(ctx, &Metadata{
+		Transport:  "websocket",
+		RemoteAddr: r.RemoteAddr,
+		LocalAddr:  localAddr(r),
+		Header:     r.Header,
+		TLS:        r.TLS,
+	})
+	resolveFrames(connCtx, resolver, conn, conn, ws.Parallel)
+	switch {
+	case conn.tooBig:
+		conn.close(wsCloseTooBig)
+	case conn.isDraining():
+		conn.close(wsCloseGoingAway)
+	default:
+		conn.close(wsCloseNormal)
+	}
+}
+
+// checkOrigin allows requests without Origin header (non-browser clients),
+// any origin for "*", exact CORSOrigin match or same origin if CORSOrigin is empty.
+func (ws *WebSocket) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	switch {
+	case origin == "", ws.CORSOrigin == "*":
+		return true
+	case ws.CORSOrigin != "":
+		return origin == ws.CORSOrigin
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func wsAcceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// wsConn implements server side of websocket framing (RFC 6455).
+type wsConn struct {
+	conn        net.Conn
+	rd          *bufio.Reader
+	wr          *bufio.Writer
+	maxSize     int64
+	idleTimeout time.Duration
+	mu          sync.Mutex // guards writes
+	closed      bool
+	rmu         sync.Mutex // guards read deadline
+	draining    bool
+	tooBig      bool // message over size limit was read, used only by reader
+	done        chan struct{}
+}
+
+// ReadFrame returns payload of next data message. Control frames are handled internally.
+// Message over size limit is reported by error wrapping ErrMessageTooLarge, so resolver can respond to it,
+// and connection is closed with 1009 status on next call.
+func (c *wsConn) ReadFrame() ([]byte, error) {
+	if c.tooBig {
+		c.close(wsCloseTooBig)
+		return nil, io.EOF
+	}
+	var msg []byte
+	started := false
+	for {
+		c.rmu.Lock()
+		if c.draining {
+			c.rmu.Unlock()
+			return nil, io.EOF
+		}
+		if c.idleTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.idleTimeout))
+		}
+		c.rmu.Unlock()
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			switch err {
+			case errWSProtocol:
+				c.close(wsCloseProtocolError)
+			case errWSTooBig:
+				c.tooBig = true
+			}
+			return nil, err
+		}
+		switch opcode {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsOpPong:
+			continue
+		case wsOpClose:
+			code := wsCloseNormal
+			if len(payload) >= 2 {
+				code = int(binary.BigEndian.Uint16(payload))
+			}
+			c.close(code)
+			return nil, io.EOF
+		case wsOpText, wsOpBinary:
+			if started {
+				c.close(wsCloseProtocolError)
+				return nil, errWSProtocol
+			}
+			started = true
+		case wsOpContinuation:
+			if !started {
+				c.close(wsCloseProtocolError)
+				return nil, errWSProtocol
+			}
+		default:
+			c.close(wsCloseProtocolError)
+			return nil, errWSProtocol
+		}
+		if int64(len(msg)+len(payload)) > c.maxSize {
+			c.tooBig = true
+			return nil, errWSTooBig
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// WriteFrame sends p as single text message.
+func (c *wsConn) WriteFrame(p []byte) error {
+	return c.writeFrame(wsOpText, trimNewline(p))
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	header := make([]byte, 2, 8)
+	if _, err = io.ReadFull(c.rd, header); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		// extensions are not negotiated, so reserved bits must be zero
+		return fin, opcode, nil, errWSProtocol
+	}
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7F)
+	if !masked {
+		// client frames must be masked
+		return fin, opcode, nil, errWSProtocol
+	}
+	isControl := opcode&0x8 != 0
+	if isControl && (!fin || length > 125) {
+		return fin, opcode, nil, errWSProtocol
+	}
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(c.rd, ext); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(c.rd, ext); err != nil {
+			return
+		}
+		length = int64(binary.BigEndian.Uint64(ext))
+		if length < 0 {
+			return fin, opcode, nil, errWSProtocol
+		}
+	}
+	if !isControl && length > c.maxSize {
+		return fin, opcode, nil, errWSTooBig
+	}
+	mask := make([]byte, 4)
+	if _, err = io.ReadFull(c.rd, mask); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.rd, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *wsConn) writeFrameLocked(opcode byte, payload []byte) error {
+	header := []byte{0x80 | opcode, 0}
+	switch l := len(payload); {
+	case l <= 125:
+		header[1] = byte(l)
+	case l <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := c.wr.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.wr.Write(payload); err != nil {
+		return err
+	}
+	return c.wr.Flush()
+}
+
+// drain stops reading of new messages, so resolver finishes in-flight requests and returns.
+func (c *wsConn) drain() {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	c.draining = true
+	c.conn.SetReadDeadline(time.Now())
+}
+
+func (c *wsConn) isDraining() bool {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	return c.draining
+}
+
+func (c *wsConn) keepalive(interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.writeFrame(wsOpPing, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// close sends close frame with given status code and closes connection.
+func (c *wsConn) close(code int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	_ = c.writeFrameLocked(wsOpClose, payload)
+	c.closed = true
+	c.conn.Close()
+}
@@ -0,0 +1,360 @@
+//Package transport provides transports for rpc server
+//
+//Copyright (C) 2022 Alexander Kiryukhin <i@neonxp.dev>
+//
+//This file is part of go.neonxp.dev/jsonrpc2 project.
+//
+//This program is free software: you can redistribute it and/or modify
+//it under the terms of the GNU General Public License as published by
+//the Free Software Foundation, either version 3 of the License, or
+//(at your option) any later version.
+//
+//This program is distributed in the hope that it will be useful,
+//but WITHOUT ANY WARRANTY; without even the implied warranty of
+//MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+//GNU General Public License for more details.
+//
+//You should have received a copy of the GNU General Public License
+//along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// echoResolver sends every frame back and responds "too large" to oversized ones like rpc server does.
+type echoResolver struct{}
+
+func (echoResolver) Resolve(ctx context.Context, r io.Reader, w io.Writer, parallel bool) {}
+
+func (echoResolver) ResolveFrames(ctx context.Context, r FrameReader, w FrameWriter, parallel bool) {
+	for {
+		frame, err := r.ReadFrame()
+		if errors.Is(err, ErrMessageTooLarge) {
+			frame, err = []byte("too large"), nil
+		}
+		if err != nil {
+			return
+		}
+		if err := w.WriteFrame(frame); err != nil {
+			return
+		}
+	}
+}
+
+func newWSServer(t *testing.T, ws *WebSocket) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.serve(context.Background(), echoResolver{}, w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+type wsFrame struct {
+	fin    bool
+	rsv    byte
+	opcode byte
+	masked bool
+	length int64 // overrides announced length if not zero
+	data   []byte
+}
+
+func (f wsFrame) bytes() []byte {
+	b0 := f.opcode | f.rsv<<4
+	if f.fin {
+		b0 |= 0x80
+	}
+	buf := []byte{b0}
+	var mask byte
+	if f.masked {
+		mask = 0x80
+	}
+	length := int64(len(f.data))
+	if f.length != 0 {
+		length = f.length
+	}
+	switch {
+	case length <= 125:
+		buf = append(buf, mask|byte(length))
+	case length <= 0xFFFF:
+		buf = append(buf, mask|126, 0, 0)
+		binary.BigEndian.PutUint16(buf[2:], uint16(length))
+	default:
+		buf = append(buf, mask|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(buf[2:], uint64(length))
+	}
+	if !f.masked {
+		return append(buf, f.data...)
+	}
+	key := []byte{1, 2, 3, 4}
+	buf = append(buf, key...)
+	for i, c := range f.data {
+		buf = append(buf, c^key[i%4])
+	}
+	return buf
+}
+
+func text(s string) wsFrame {
+	return wsFrame{fin: true, opcode: wsOpText, masked: true, data: []byte(s)}
+}
+
+func closeCode(code int) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(code))
+	return b
+}
+
+// dialWS performs handshake and returns connection with reader positioned after response headers.
+func dialWS(t *testing.T, srv *httptest.Server) (net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	req := "GET / HTTP/1.1\r\nHost: " + srv.Listener.Addr().String() + "\r\n" +
+		"Connection: Upgrade\r\nUpgrade: websocket\r\nSec-WebSocket-Version: 13\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	rd := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(rd, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("handshake status = %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, rd
+}
+
+// readServerFrame reads single unmasked frame sent by server.
+func readServerFrame(rd *bufio.Reader) (byte, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(rd, header); err != nil {
+		return 0, nil, err
+	}
+	if header[1]&0x80 != 0 {
+		return 0, nil, errWSProtocol
+	}
+	length := int64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(rd, ext); err != nil {
+			return 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(rd, ext); err != nil {
+			return 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext))
+	}
+	payload := make([]byte, length)
+	_, err := io.ReadFull(rd, payload)
+	return header[0] & 0x0F, payload, err
+}
+
+func TestWSAcceptKey(t *testing.T) {
+	// example from RFC 6455, section 1.3
+	if got := wsAcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("wsAcceptKey() = %q", got)
+	}
+}
+
+func TestWSHandshake(t *testing.T) {
+	valid := map[string]string{
+		"Connection":            "keep-alive, Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	}
+	tests := []struct {
+		name   string
+		method string
+		cors   string
+		header map[string]string // overrides valid headers, empty value removes header
+		want   int
+	}{
+		{"valid", "GET", "", nil, http.StatusSwitchingProtocols},
+		{"post", "POST", "", nil, http.StatusMethodNotAllowed},
+		{"no upgrade", "GET", "", map[string]string{"Upgrade": ""}, http.StatusUpgradeRequired},
+		{"no connection upgrade", "GET", "", map[string]string{"Connection": "keep-alive"}, http.StatusUpgradeRequired},
+		{"old version", "GET", "", map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusBadRequest},
+		{"invalid key", "GET", "", map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{"same origin", "GET", "", map[string]string{"Origin": "http://{host}"}, http.StatusSwitchingProtocols},
+		{"other origin", "GET", "", map[string]string{"Origin": "http://evil.example"}, http.StatusForbidden},
+		{"allowed origin", "GET", "https://app.example", map[string]string{"Origin": "https://app.example"}, http.StatusSwitchingProtocols},
+		{"not allowed origin", "GET", "https://app.example", map[string]string{"Origin": "http://{host}"}, http.StatusForbidden},
+		{"any origin", "GET", "*", map[string]string{"Origin": "http://evil.example"}, http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWSServer(t, &WebSocket{CORSOrigin: tt.cors})
+			req, err := http.NewRequest(tt.method, srv.URL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range valid {
+				req.Header.Set(k, v)
+			}
+			for k, v := range tt.header {
+				if v == "" {
+					req.Header.Del(k)
+					continue
+				}
+				req.Header.Set(k, strings.ReplaceAll(v, "{host}", req.Host))
+			}
+			resp, err := http.DefaultTransport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestWSFraming(t *testing.T) {
+	type serverFrame struct {
+		opcode byte
+		data   []byte
+	}
+	long := strings.Repeat("x", 70000)
+	tests := []struct {
+		name    string
+		maxSize int64
+		send    []wsFrame
+		want    []serverFrame // frames until connection is closed
+	}{
+		{
+			name: "text",
+			send: []wsFrame{text("hello")},
+			want: []serverFrame{{wsOpText, []byte("hello")}},
+		},
+		{
+			name: "16 bit length",
+			send: []wsFrame{text(strings.Repeat("y", 300))},
+			want: []serverFrame{{wsOpText, []byte(strings.Repeat("y", 300))}},
+		},
+		{
+			name: "64 bit length",
+			send: []wsFrame{text(long)},
+			want: []serverFrame{{wsOpText, []byte(long)}},
+		},
+		{
+			name: "fragmented with ping between fragments",
+			send: []wsFrame{
+				{opcode: wsOpText, masked: true, data: []byte("he")},
+				{fin: true, opcode: wsOpPing, masked: true, data: []byte("p")},
+				{opcode: wsOpContinuation, masked: true, data: []byte("l")},
+				{fin: true, opcode: wsOpContinuation, masked: true, data: []byte("lo")},
+			},
+			want: []serverFrame{{wsOpPong, []byte("p")}, {wsOpText, []byte("hello")}},
+		},
+		{
+			name: "pong is ignored",
+			send: []wsFrame{{fin: true, opcode: wsOpPong, masked: true}, text("a")},
+			want: []serverFrame{{wsOpText, []byte("a")}},
+		},
+		{
+			name: "close by client",
+			send: []wsFrame{{fin: true, opcode: wsOpClose, masked: true, data: closeCode(wsCloseNormal)}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseNormal)}},
+		},
+		{
+			name: "unmasked frame",
+			send: []wsFrame{{fin: true, opcode: wsOpText, data: []byte("a")}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "reserved bits",
+			send: []wsFrame{{fin: true, rsv: 4, opcode: wsOpText, masked: true, data: []byte("a")}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "unknown opcode",
+			send: []wsFrame{{fin: true, opcode: 0x3, masked: true, data: []byte("a")}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "continuation without start",
+			send: []wsFrame{{fin: true, opcode: wsOpContinuation, masked: true, data: []byte("a")}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "new message inside fragmented one",
+			send: []wsFrame{{opcode: wsOpText, masked: true, data: []byte("a")}, text("b")},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "fragmented control frame",
+			send: []wsFrame{{opcode: wsOpPing, masked: true, data: []byte("a")}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name: "long control frame",
+			send: []wsFrame{{fin: true, opcode: wsOpPing, masked: true, data: bytes.Repeat([]byte("a"), 126)}},
+			want: []serverFrame{{wsOpClose, closeCode(wsCloseProtocolError)}},
+		},
+		{
+			name:    "frame too big",
+			maxSize: 4,
+			send:    []wsFrame{{fin: true, opcode: wsOpText, masked: true, length: 1 << 40}},
+			want:    []serverFrame{{wsOpText, []byte("too large")}, {wsOpClose, closeCode(wsCloseTooBig)}},
+		},
+		{
+			name:    "fragments too big",
+			maxSize: 4,
+			send: []wsFrame{
+				{opcode: wsOpText, masked: true, data: []byte("abc")},
+				{fin: true, opcode: wsOpContinuation, masked: true, data: []byte("de")},
+			},
+			want: []serverFrame{{wsOpText, []byte("too large")}, {wsOpClose, closeCode(wsCloseTooBig)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newWSServer(t, &WebSocket{MaxMessageSize: tt.maxSize})
+			conn, rd := dialWS(t, srv)
+			go func() {
+				for _, f := range tt.send {
+					if _, err := conn.Write(f.bytes()); err != nil {
+						return
+					}
+				}
+			}()
+			for i, want := range tt.want {
+				opcode, data, err := readServerFrame(rd)
+				if err != nil {
+					t.Fatalf("frame %d: %v", i, err)
+				}
+				if opcode != want.opcode || !bytes.Equal(data, want.data) {
+					t.Fatalf("frame %d = %x %.20q, want %x %.20q", i, opcode, data, want.opcode, want.data)
+				}
+			}
+		})
+	}
+}